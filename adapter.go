@@ -2,15 +2,21 @@ package llmberjack
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"reflect"
 
 	"github.com/checkmarble/llmberjack/internal"
 	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
 )
 
 const (
-	defaultProvider = "__DEFAULT__"
+	// DefaultProviderName is the name of the provider set with
+	// `WithDefaultProvider()`, under which it is reported to middlewares, logs
+	// and traces, and can be configured with options such as `WithRateLimit()`.
+	DefaultProviderName = "default"
 )
 
 // Llm defines the interface that all LLM providers must implement.
@@ -37,11 +43,133 @@ type Llm interface {
 	RequestOptionsType() reflect.Type
 }
 
+// StreamingLlm is implemented by providers that are able to stream their
+// responses as they are generated.
+//
+// It is optional, and requests can only be streamed on providers implementing
+// it.
+type StreamingLlm interface {
+	Llm
+
+	// ChatCompletionStream sends a chat completion request to the LLM provider
+	// and calls the provided callback for every delta received. Once the stream
+	// is over, it returns the aggregated response, as `ChatCompletion` would.
+	//
+	// If the callback returns false, the stream must be interrupted and
+	// `ErrStreamStopped` returned.
+	ChatCompletionStream(context.Context, internal.Adapter, Requester, func(StreamDelta) bool) (*InnerResponse, error)
+}
+
+// Renderer is implemented by providers able to show the payload they would
+// send for a request, without sending it.
+//
+// It is optional, and requests can only be rendered on providers implementing
+// it.
+type Renderer interface {
+	Llm
+
+	// Render adapts a request for the provider, including the history of its
+	// thread, and returns the resulting API payload serialized to JSON. It must
+	// not send anything, nor modify the history of the thread.
+	Render(internal.Adapter, Requester) (json.RawMessage, error)
+}
+
+// TokenCounter is implemented by providers able to count the tokens of a
+// request before sending it.
+//
+// It is optional, and tokens can only be counted on providers implementing it.
+type TokenCounter interface {
+	Llm
+
+	// CountTokens returns the number of input tokens of a request, including
+	// the history of its thread, the system instructions, and the schemas of
+	// tools and of the response. It must not modify the history of the thread.
+	CountTokens(context.Context, internal.Adapter, Requester) (TokenCount, error)
+}
+
+// HistoryCompactor is implemented by providers whose thread histories can be
+// bounded by a `HistoryPolicy`.
+//
+// It is optional, and history policies can only be set on threads of providers
+// implementing it.
+type HistoryCompactor interface {
+	Llm
+
+	// ThreadHistory describes the messages of the history of a thread, in
+	// order.
+	ThreadHistory(*ThreadId) []HistoryMessage
+	// CompactThread removes the `count` oldest messages which are not system
+	// instructions from the history of a thread. If a summary is provided, it
+	// is added as a user message in their place.
+	CompactThread(threadId *ThreadId, count int, summary string)
+}
+
+// HistoryWriter is implemented by providers able to add to the history of a
+// thread a response they did not generate, such as one served from the cache.
+//
+// It is optional, and requests bound to a thread can only be cached on
+// providers implementing it, as well as `HistoryCompactor`.
+type HistoryWriter interface {
+	Llm
+
+	// SaveInputs adds the messages of a request to the history of its thread,
+	// as if it was sent to the provider.
+	SaveInputs(internal.Adapter, Requester) error
+	// SaveOutput adds a candidate to the history of the thread of a request, as
+	// if it was selected from a response of the provider.
+	SaveOutput(Requester, ResponseCandidate)
+}
+
+// ModelDefaulter is implemented by providers configured with a default model,
+// used for requests which do not select one, in place of the default model of
+// the adapter.
+//
+// It is optional, but the model requests are executed with cannot be reported
+// accurately, for caching, logging and tracing, on providers with a default
+// model not implementing it.
+type ModelDefaulter interface {
+	Llm
+
+	// DefaultModel returns the default model of the provider, or an empty
+	// string if it has none.
+	DefaultModel() string
+}
+
+// SystemNamer is implemented by providers naming the generative AI system they
+// send requests to, as defined by the OpenTelemetry semantic conventions, such
+// as `openai` or `gcp.gemini`.
+//
+// It is optional, and requests sent to providers not implementing it are traced
+// with the name the provider was registered under.
+type SystemNamer interface {
+	Llm
+
+	// SystemName returns the value of the `gen_ai.system` attribute of the
+	// requests sent to the provider.
+	SystemName() string
+}
+
 // Llmberjack is the main entrypoint for interacting with different LLM providers.
 // It provides a unified interface to send requests and receive responses.
 type Llmberjack struct {
-	providers       map[string]Llm
-	defaultProvider Llm
+	providers           map[string]Llm
+	defaultProvider     Llm
+	defaultProviderName string
+
+	middlewares       []Middleware
+	handler           Handler
+	retryPolicy       *RetryPolicy
+	fallbackProviders []string
+	rateLimiters      map[string]*rateLimiter
+	circuitBreaker    *CircuitBreaker
+	circuitBreakers   map[string]*circuitBreaker
+	cacheConfig       *cacheConfig
+	pricing           *Pricing
+	cost              *costTracker
+	budget            *Budget
+	tracing           *Tracing
+	logger            *slog.Logger
+	redactor          Redactor
 
 	httpClient   *http.Client
 	defaultModel string
@@ -59,19 +187,42 @@ type Llmberjack struct {
 //	)
 func New(opts ...llmOption) (*Llmberjack, error) {
 	llm := Llmberjack{
-		providers: make(map[string]Llm),
+		providers:       make(map[string]Llm),
+		rateLimiters:    make(map[string]*rateLimiter),
+		circuitBreakers: make(map[string]*circuitBreaker),
+		cost:            &costTracker{},
 	}
 
 	for _, opt := range opts {
 		opt(&llm)
 	}
 
+	for _, name := range llm.fallbackProviders {
+		if _, ok := llm.providers[name]; !ok {
+			return nil, errors.Newf("unknown fallback provider '%s'", name)
+		}
+	}
+
+	for name := range llm.rateLimiters {
+		if _, ok := llm.providers[name]; !ok {
+			return nil, errors.Newf("rate limit configured for unknown provider '%s'", name)
+		}
+	}
+
 	for name, provider := range llm.providers {
 		if err := provider.Init(llm); err != nil {
 			return nil, errors.Wrapf(err, "could not initialize LLM provider '%s'", name)
 		}
 	}
 
+	if llm.circuitBreaker != nil {
+		for name := range llm.providers {
+			llm.circuitBreakers[name] = newCircuitBreaker(*llm.circuitBreaker)
+		}
+	}
+
+	llm.handler = llm.buildHandler()
+
 	return &llm, nil
 }
 
@@ -109,6 +260,61 @@ func (llm *Llmberjack) GetProvider(requestProvider *string) (Llm, error) {
 	return provider, nil
 }
 
+// providerName returns the name under which the provider selected by a request
+// was registered.
+func (llm *Llmberjack) providerName(requestProvider *string) string {
+	if requestProvider != nil {
+		return *requestProvider
+	}
+
+	return llm.defaultProviderName
+}
+
+// wrappingLlm is implemented by providers wrapping another one, such as the
+// `Recorder`, which only support the optional interfaces of the provider they
+// wrap.
+type wrappingLlm interface {
+	Unwrap() Llm
+}
+
+// supports returns a provider as one of the optional provider interfaces, if it
+// and the providers it wraps all implement it.
+func supports[T Llm](provider Llm) (T, bool) {
+	capable, ok := provider.(T)
+	if !ok {
+		return capable, false
+	}
+
+	for wrapper, ok := provider.(wrappingLlm); ok; wrapper, ok = provider.(wrappingLlm) {
+		if provider = wrapper.Unwrap(); provider == nil {
+			return lo.Empty[T](), false
+		}
+
+		if _, ok := provider.(T); !ok {
+			return lo.Empty[T](), false
+		}
+	}
+
+	return capable, true
+}
+
+// resolveModel returns the model a request is executed with on a provider: the
+// model selected by the request, then the default model of the provider, then
+// the one of the adapter.
+func resolveModel(llm internal.Adapter, provider Llm, r innerRequest) string {
+	if model := lo.FromPtr(r.Model); model != "" {
+		return model
+	}
+
+	if defaulter, ok := provider.(ModelDefaulter); ok {
+		if model := defaulter.DefaultModel(); model != "" {
+			return model
+		}
+	}
+
+	return llm.DefaultModel()
+}
+
 // Llmberjack implementation of Adapter
 
 func (llm Llmberjack) DefaultModel() string {
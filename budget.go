@@ -0,0 +1,166 @@
+package llmberjack
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+)
+
+// ErrBudgetExceeded is returned, before sending a request, when a budget it is
+// subject to was exhausted.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetLimits defines the limits of a budget. Zero values are not enforced.
+type BudgetLimits struct {
+	// MaxTokens is the maximum number of tokens, as reported in the usage of
+	// responses.
+	MaxTokens int
+	// MaxCost is the maximum estimated cost, in the currency of the pricing
+	// registry. Requests whose cost is unknown are not accounted for.
+	MaxCost float64
+	// MaxCalls is the maximum number of calls dispatched to providers,
+	// including failed attempts, retries and fallbacks. Responses served from
+	// the cache are not counted.
+	MaxCalls int
+}
+
+// BudgetUsage is the consumption recorded against a budget.
+type BudgetUsage struct {
+	Tokens int
+	Cost   float64
+	Calls  int
+}
+
+// Budget limits the consumption of a set of requests.
+//
+// A budget can be attached to the adapter with `WithBudget()`, to a thread with
+// `ThreadId.SetBudget()`, or to a context with `ContextWithBudget()`, and the
+// same budget can be shared between several of those. Requests subject to an
+// exhausted budget fail with `ErrBudgetExceeded` before being sent or retried,
+// and calls and responses are recorded against all the budgets the request was
+// subject to.
+//
+// Budgets are checked before sending requests, so concurrent requests can
+// overshoot them.
+type Budget struct {
+	mtx    sync.Mutex
+	limits BudgetLimits
+	usage  BudgetUsage
+}
+
+// NewBudget creates a budget with the provided limits.
+func NewBudget(limits BudgetLimits) *Budget {
+	return &Budget{limits: limits}
+}
+
+// Usage returns the consumption recorded against the budget.
+func (b *Budget) Usage() BudgetUsage {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.usage
+}
+
+// Exceeded returns an error matching `ErrBudgetExceeded` if any limit of the
+// budget was reached.
+func (b *Budget) Exceeded() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch {
+	case b.limits.MaxTokens > 0 && b.usage.Tokens >= b.limits.MaxTokens:
+		return errors.Wrapf(ErrBudgetExceeded, "%d/%d tokens used", b.usage.Tokens, b.limits.MaxTokens)
+	case b.limits.MaxCost > 0 && b.usage.Cost >= b.limits.MaxCost:
+		return errors.Wrapf(ErrBudgetExceeded, "%f/%f spent", b.usage.Cost, b.limits.MaxCost)
+	case b.limits.MaxCalls > 0 && b.usage.Calls >= b.limits.MaxCalls:
+		return errors.Wrapf(ErrBudgetExceeded, "%d/%d calls made", b.usage.Calls, b.limits.MaxCalls)
+	}
+
+	return nil
+}
+
+func (b *Budget) record(usage Usage, cost Cost) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.usage.Tokens += usage.TotalTokens
+	b.usage.Cost += cost.Amount
+}
+
+func (b *Budget) recordCall() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.usage.Calls += 1
+}
+
+type budgetContextKey struct{}
+
+// ContextWithBudget returns a context subjecting all requests executed with it
+// to the provided budget.
+//
+// Budgets attached to a context accumulate: a request is subject to the budgets
+// of all its parent contexts.
+func ContextWithBudget(ctx context.Context, budget *Budget) context.Context {
+	budgets := append(slices.Clip(budgetsFromContext(ctx)), budget)
+
+	return context.WithValue(ctx, budgetContextKey{}, budgets)
+}
+
+func budgetsFromContext(ctx context.Context) []*Budget {
+	budgets, _ := ctx.Value(budgetContextKey{}).([]*Budget)
+
+	return budgets
+}
+
+// budgets returns all the budgets a request is subject to, each budget being
+// returned once even if it was attached to several scopes.
+func (llm *Llmberjack) budgets(ctx context.Context, threadId *ThreadId) []*Budget {
+	budgets := slices.Clip(budgetsFromContext(ctx))
+
+	if llm.budget != nil {
+		budgets = append(budgets, llm.budget)
+	}
+	if threadId != nil {
+		if budget := threadId.budget.Load(); budget != nil {
+			budgets = append(budgets, budget)
+		}
+	}
+
+	return lo.Uniq(budgets)
+}
+
+// checkBudgets returns an error if any of the budgets a request is subject to
+// was exhausted.
+func (llm *Llmberjack) checkBudgets(ctx context.Context, threadId *ThreadId) error {
+	for _, budget := range llm.budgets(ctx, threadId) {
+		if err := budget.Exceeded(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordCall records a call dispatched to a provider against all budgets the
+// request is subject to.
+func (llm *Llmberjack) recordCall(ctx context.Context, threadId *ThreadId) {
+	for _, budget := range llm.budgets(ctx, threadId) {
+		budget.recordCall()
+	}
+}
+
+// recordBudgets records the consumption of a response against all budgets the
+// request was subject to. Responses served from the cache are not recorded.
+func (llm *Llmberjack) recordBudgets(ctx context.Context, threadId *ThreadId, resp *InnerResponse, cost Cost) {
+	if resp.Cached {
+		return
+	}
+
+	for _, budget := range llm.budgets(ctx, threadId) {
+		budget.record(resp.Usage, cost)
+	}
+}
@@ -0,0 +1,147 @@
+package llmberjack
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func withTestUsage(usage Usage) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			resp, err := next(ctx, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Model = "themodel"
+			resp.Usage = usage
+
+			return resp, nil
+		}
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	tts := []struct {
+		limits BudgetLimits
+		usage  BudgetUsage
+		err    string
+	}{
+		{BudgetLimits{}, BudgetUsage{Tokens: 1000, Cost: 10, Calls: 10}, ""},
+		{BudgetLimits{MaxTokens: 100}, BudgetUsage{Tokens: 99}, ""},
+		{BudgetLimits{MaxTokens: 100}, BudgetUsage{Tokens: 100}, "100/100 tokens used"},
+		{BudgetLimits{MaxCost: 1}, BudgetUsage{Cost: 1.5}, "spent"},
+		{BudgetLimits{MaxCalls: 2}, BudgetUsage{Calls: 2}, "2/2 calls made"},
+	}
+
+	for _, tt := range tts {
+		budget := NewBudget(tt.limits)
+		budget.usage = tt.usage
+
+		err := budget.Exceeded()
+
+		if tt.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.ErrorIs(t, err, ErrBudgetExceeded)
+			assert.ErrorContains(t, err, tt.err)
+		}
+	}
+}
+
+func TestBudgetScopes(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	adapterBudget := NewBudget(BudgetLimits{MaxCalls: 4})
+
+	llm, _ := New(
+		WithDefaultProvider(p),
+		WithMiddleware(withTestUsage(Usage{PromptTokens: 500_000, CompletionTokens: 500_000, TotalTokens: 1_000_000})),
+		WithPricing(NewPricing().Set(AnyProvider, "themodel", ModelPricing{Input: 1, Output: 1})),
+		WithBudget(adapterBudget),
+	)
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	// Thread budget.
+	resp, err := NewUntypedRequest().CreateThread().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	threadBudget := NewBudget(BudgetLimits{MaxTokens: 1_000_000})
+	resp.ThreadId.SetBudget(threadBudget)
+
+	_, err = NewUntypedRequest().InThread(resp.ThreadId).Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	_, err = NewUntypedRequest().InThread(resp.ThreadId).Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, BudgetUsage{Tokens: 1_000_000, Cost: 1, Calls: 1}, threadBudget.Usage())
+	p.AssertNumberOfCalls(t, "ChatCompletion", 2)
+
+	// Context budget, shared with the adapter.
+	ctxBudget := NewBudget(BudgetLimits{MaxCost: 0.5})
+	ctx := ContextWithBudget(ContextWithBudget(t.Context(), ctxBudget), adapterBudget)
+
+	_, err = NewUntypedRequest().Do(ctx, llm)
+
+	assert.Nil(t, err)
+
+	_, err = NewUntypedRequest().Do(ctx, llm)
+
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, BudgetUsage{Tokens: 1_000_000, Cost: 1, Calls: 1}, ctxBudget.Usage())
+
+	// Adapter budget, which was only recorded once per response.
+	_, err = NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, adapterBudget.Usage().Calls)
+
+	_, err = NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+}
+
+func TestBudgetCallsAttempts(t *testing.T) {
+	p1 := NewMockProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithProvider("p1", p1),
+		WithProvider("p2", p2),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	// Failed attempts, retries and fallbacks are all counted.
+	budget := NewBudget(BudgetLimits{MaxCalls: 4})
+
+	_, err := NewUntypedRequest().WithFallbackProviders("p2").Do(ContextWithBudget(t.Context(), budget), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, budget.Usage().Calls)
+
+	// The budget is checked before each retry.
+	budget = NewBudget(BudgetLimits{MaxCalls: 2})
+
+	_, err = NewUntypedRequest().Do(ContextWithBudget(t.Context(), budget), llm)
+
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, 2, budget.Usage().Calls)
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 5)
+}
@@ -0,0 +1,220 @@
+package llmberjack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// CacheStore is a storage backend for cached responses.
+//
+// Implementations must be safe for concurrent use. The library provides an
+// in-memory LRU store with `NewMemoryCache()` and an on-disk store with
+// `NewDiskCache()`.
+type CacheStore interface {
+	// Get retrieves the value stored under a key, if it exists and did not
+	// expire.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores a value under a key. A zero TTL means the value does not
+	// expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type cacheConfig struct {
+	store CacheStore
+	ttl   time.Duration
+}
+
+// cacheKeyFor computes the cache key of a request executed on a provider.
+//
+// It returns false if the request cannot be cached, because it is bound to a
+// thread without opting in with `CacheInThread()`, or on a provider which
+// cannot describe and write its history, or because some of its message parts
+// cannot be read without being consumed.
+func (llm *Llmberjack) cacheKeyFor(providerName string, provider Llm, r innerRequest) (string, bool) {
+	var history string
+
+	if r.ThreadId != nil {
+		digest, ok := threadDigest(provider, r)
+		if !ok {
+			return "", false
+		}
+
+		history = digest
+	}
+
+	canonical, ok := newCanonicalRequest(providerName, resolveModel(llm, provider, r), r)
+	if !ok {
+		return "", false
+	}
+
+	key, ok := canonical.hash()
+	if !ok || r.ThreadId == nil {
+		return key, ok
+	}
+
+	return chainDigest(history, key), true
+}
+
+// threadDigest hashes the history of the thread a request is bound to, if it
+// opted in to being cached.
+func threadDigest(provider Llm, r innerRequest) (string, bool) {
+	if !r.CacheInThread {
+		return "", false
+	}
+
+	compactor, ok := supports[HistoryCompactor](provider)
+	if !ok {
+		return "", false
+	}
+	if _, ok := supports[HistoryWriter](provider); !ok {
+		return "", false
+	}
+
+	buf, err := json.Marshal(compactor.ThreadHistory(r.ThreadId))
+	if err != nil {
+		return "", false
+	}
+
+	hash := sha256.Sum256(buf)
+
+	return hex.EncodeToString(hash[:]), true
+}
+
+// cache wraps a handler to serve responses from the cache, and store new
+// responses in it.
+//
+// A cached response streamed back emits its whole text as one delta per
+// candidate. When the request is bound to a thread, the cached response is
+// added to its history as if it was generated by the provider.
+func (llm *Llmberjack) cache(next Handler) Handler {
+	return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+		r := inv.Requester.ToRequest()
+
+		if llm.cacheConfig == nil || r.NoCache {
+			return next(ctx, inv)
+		}
+
+		key, ok := llm.cacheKeyFor(inv.ProviderName, inv.Provider, r)
+		if !ok {
+			return next(ctx, inv)
+		}
+
+		if buf, ok, err := llm.cacheConfig.store.Get(ctx, key); err == nil && ok {
+			var resp InnerResponse
+
+			if err := json.Unmarshal(buf, &resp); err == nil && saveCachedInputs(llm, inv) {
+				resp.Cached = true
+
+				for idx := range resp.Candidates {
+					resp.Candidates[idx].SelectCandidate = cachedSelector(inv, resp.Candidates[idx])
+
+					if inv.Streaming() && !inv.OnDelta(cachedDelta(idx, resp.Candidates[idx])) {
+						return nil, ErrStreamStopped
+					}
+				}
+
+				return &resp, nil
+			}
+		}
+
+		resp, err := next(ctx, inv)
+		if err != nil {
+			return nil, err
+		}
+
+		ttl := llm.cacheConfig.ttl
+		if r.CacheTTL != nil {
+			ttl = *r.CacheTTL
+		}
+
+		if buf, err := json.Marshal(resp); err == nil {
+			// Failing to populate the cache should not fail the request.
+			_ = llm.cacheConfig.store.Set(ctx, key, buf, ttl)
+		}
+
+		return resp, nil
+	}
+}
+
+// saveCachedInputs adds the messages of a request served from the cache to the
+// history of its thread. If it fails, the request is sent to the provider.
+func saveCachedInputs(llm *Llmberjack, inv Invocation) bool {
+	if inv.Requester.ToRequest().ThreadId == nil {
+		return true
+	}
+
+	writer, ok := supports[HistoryWriter](inv.Provider)
+	if !ok {
+		return false
+	}
+
+	return writer.SaveInputs(llm, inv.Requester) == nil
+}
+
+// cachedSelector adds a cached candidate to the history of the thread of the
+// request, when it is selected.
+func cachedSelector(inv Invocation, candidate ResponseCandidate) func() {
+	if inv.Requester.ToRequest().ThreadId == nil {
+		return func() {}
+	}
+
+	writer, ok := supports[HistoryWriter](inv.Provider)
+	if !ok {
+		return func() {}
+	}
+
+	return func() {
+		writer.SaveOutput(inv.Requester, candidate)
+	}
+}
+
+func cachedDelta(idx int, candidate ResponseCandidate) StreamDelta {
+	delta := StreamDelta{
+		Candidate: idx,
+		Text:      candidate.Text,
+		Thoughts:  candidate.Thoughts,
+		ToolCalls: make([]StreamToolCallDelta, 0, len(candidate.ToolCalls)),
+	}
+
+	for toolIdx, call := range candidate.ToolCalls {
+		delta.ToolCalls = append(delta.ToolCalls, StreamToolCallDelta{
+			Index:      toolIdx,
+			Id:         call.Id,
+			Name:       call.Name,
+			Parameters: string(call.Parameters),
+		})
+	}
+
+	return delta
+}
+
+// CacheInThread allows the request to be cached even though it is bound to a
+// thread. The history of the thread is then part of the cache key, and a
+// cached response is added to it as if it was generated by the provider.
+//
+// It is only supported by providers implementing `HistoryCompactor` and
+// `HistoryWriter`, and ignored on others.
+func (r Request[T]) CacheInThread() Request[T] {
+	r.innerRequest.CacheInThread = true
+
+	return r
+}
+
+// NoCache prevents the request from being served from, or stored in, the
+// cache.
+func (r Request[T]) NoCache() Request[T] {
+	r.innerRequest.NoCache = true
+
+	return r
+}
+
+// WithCacheTTL overrides how long the response to this request will be kept in
+// the cache. A zero TTL means the response does not expire.
+func (r Request[T]) WithCacheTTL(ttl time.Duration) Request[T] {
+	r.CacheTTL = &ttl
+
+	return r
+}
@@ -0,0 +1,233 @@
+package llmberjack
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type modelMockProvider struct {
+	*MockProvider
+
+	model string
+}
+
+func (p modelMockProvider) DefaultModel() string {
+	return p.model
+}
+
+func TestCacheKey(t *testing.T) {
+	llm, _ := New(WithDefaultModel("themodel"))
+
+	type Output struct {
+		Reply string `json:"reply"`
+	}
+
+	base := NewRequest[Output]().WithText(RoleUser, "Hello").WithTemperature(0.5)
+
+	key, ok := llm.cacheKeyFor("p1", nil, base.ToRequest())
+
+	assert.True(t, ok)
+
+	// The key is stable, and reading message parts does not consume them.
+	again, _ := llm.cacheKeyFor("p1", nil, base.ToRequest())
+
+	assert.Equal(t, key, again)
+
+	different := []innerRequest{
+		NewRequest[Output]().WithText(RoleUser, "Hello!").WithTemperature(0.5).ToRequest(),
+		NewRequest[Output]().WithText(RoleUser, "Hello").WithTemperature(0.6).ToRequest(),
+		NewRequest[Output]().WithText(RoleUser, "Hello").WithTemperature(0.5).WithModel("othermodel").ToRequest(),
+		NewUntypedRequest().WithText(RoleUser, "Hello").WithTemperature(0.5).ToRequest(),
+	}
+
+	for _, req := range different {
+		other, ok := llm.cacheKeyFor("p1", nil, req)
+
+		assert.True(t, ok)
+		assert.NotEqual(t, key, other)
+	}
+
+	image1, _ := llm.cacheKeyFor("p1", nil, NewUntypedRequest().WithImage(RoleUser, strings.NewReader("\xff\x01"), "image/png").ToRequest())
+	image2, _ := llm.cacheKeyFor("p1", nil, NewUntypedRequest().WithImage(RoleUser, strings.NewReader("\xff\x02"), "image/png").ToRequest())
+
+	assert.NotEqual(t, image1, image2)
+
+	other, _ := llm.cacheKeyFor("p2", nil, base.ToRequest())
+
+	assert.NotEqual(t, key, other)
+
+	// The default model of the provider is part of the key.
+	p := modelMockProvider{MockProvider: NewMockProvider(), model: "providermodel"}
+
+	withProviderModel, _ := llm.cacheKeyFor("p1", p, base.ToRequest())
+	withRequestModel, _ := llm.cacheKeyFor("p1", nil, base.WithModel("providermodel").ToRequest())
+	p.model = "othermodel"
+	withOtherProviderModel, _ := llm.cacheKeyFor("p1", p, base.ToRequest())
+
+	assert.NotEqual(t, key, withProviderModel)
+	assert.Equal(t, withRequestModel, withProviderModel)
+	assert.NotEqual(t, withProviderModel, withOtherProviderModel)
+
+	_, ok = llm.cacheKeyFor("p1", nil, NewUntypedRequest().WithTextReader(RoleUser, iotest.OneByteReader(strings.NewReader("Hello"))).ToRequest())
+
+	assert.False(t, ok)
+
+	_, ok = llm.cacheKeyFor("p1", nil, NewUntypedRequest().InThread(&ThreadId{}).WithText(RoleUser, "Hello").ToRequest())
+
+	assert.False(t, ok)
+}
+
+func TestCache(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	store := NewMemoryCache(10)
+	llm, _ := New(WithDefaultProvider(p), WithCache(store, time.Hour))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	resp, err := NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.False(t, resp.Cached)
+
+	resp, err = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	assert.NotPanics(t, func() { resp.Candidates[0].SelectCandidate() })
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+
+	resp, _ = NewUntypedRequest().WithText(RoleUser, "Hello").NoCache().Do(t.Context(), llm)
+
+	assert.False(t, resp.Cached)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 2)
+
+	resp, _ = NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.False(t, resp.Cached)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 3)
+
+	_, _ = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestCacheStream(t *testing.T) {
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithCache(NewMemoryCache(0), 0))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello, world"}, nil)
+
+	for range 2 {
+		stream, _ := NewUntypedRequest().WithText(RoleUser, "Hello").Stream(t.Context(), llm)
+
+		var sb strings.Builder
+
+		for delta, err := range stream {
+			assert.Nil(t, err)
+
+			sb.WriteString(delta.Text)
+		}
+
+		assert.Equal(t, "Hello, world", sb.String())
+	}
+
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestCacheTTL(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	now := time.Now()
+	store := NewMemoryCache(10)
+	store.now = func() time.Time { return now }
+
+	llm, _ := New(WithDefaultProvider(p), WithCache(store, time.Hour))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").WithCacheTTL(time.Minute).Do(t.Context(), llm)
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+
+	now = now.Add(time.Minute)
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	p.AssertNumberOfCalls(t, "ChatCompletion", 2)
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	store := NewMemoryCache(2)
+
+	_ = store.Set(t.Context(), "a", []byte("a"), 0)
+	_ = store.Set(t.Context(), "b", []byte("b"), 0)
+
+	// Touching "a" makes "b" the least recently used entry.
+	_, ok, _ := store.Get(t.Context(), "a")
+
+	assert.True(t, ok)
+
+	_ = store.Set(t.Context(), "c", []byte("c"), 0)
+
+	_, ok, _ = store.Get(t.Context(), "b")
+
+	assert.False(t, ok)
+
+	value, ok, _ := store.Get(t.Context(), "a")
+
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), value)
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestDiskCache(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+
+	store, err := NewDiskCache(dir)
+
+	assert.Nil(t, err)
+
+	store.now = func() time.Time { return now }
+
+	_, ok, err := store.Get(t.Context(), "key")
+
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, store.Set(t.Context(), "key", []byte("value"), time.Minute))
+	assert.Nil(t, store.Set(t.Context(), "forever", []byte("forever"), 0))
+
+	// Entries are shared across store instances.
+	other, _ := NewDiskCache(dir)
+	other.now = store.now
+
+	value, ok, err := other.Get(t.Context(), "key")
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	now = now.Add(time.Minute)
+
+	_, ok, _ = store.Get(t.Context(), "key")
+
+	assert.False(t, ok)
+
+	_, ok, _ = store.Get(t.Context(), "forever")
+
+	assert.True(t, ok)
+}
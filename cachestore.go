@@ -0,0 +1,195 @@
+package llmberjack
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryCache is an in-memory `CacheStore` evicting the least recently used
+// entries when it is full.
+type MemoryCache struct {
+	mtx sync.Mutex
+
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+
+	now func() time.Time
+}
+
+// NewMemoryCache creates an in-memory cache store holding at most `capacity`
+// responses. A capacity of zero or less means the cache is unbounded.
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*memoryCacheEntry)
+
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	entry := &memoryCacheEntry{key: key, value: value}
+
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries currently held by the cache, including
+// expired ones that were not evicted yet.
+func (c *MemoryCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.order.Len()
+}
+
+type diskCacheEntry struct {
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Value     []byte    `json:"value"`
+}
+
+// DiskCache is a `CacheStore` persisting each response as a file in a
+// directory, so it can be shared across processes and runs.
+type DiskCache struct {
+	dir string
+
+	now func() time.Time
+}
+
+// NewDiskCache creates an on-disk cache store in the given directory, creating
+// it if it does not exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "could not create cache directory")
+	}
+
+	return &DiskCache{dir: dir, now: time.Now}, nil
+}
+
+func (c *DiskCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	buf, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+
+		return nil, false, errors.Wrap(err, "could not read cache entry")
+	}
+
+	var entry diskCacheEntry
+
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return nil, false, errors.Wrap(err, "could not decode cache entry")
+	}
+
+	if !entry.ExpiresAt.IsZero() && !c.now().Before(entry.ExpiresAt) {
+		_ = os.Remove(c.path(key))
+
+		return nil, false, nil
+	}
+
+	return entry.Value, true, nil
+}
+
+func (c *DiskCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := diskCacheEntry{Value: value}
+
+	if ttl > 0 {
+		entry.ExpiresAt = c.now().Add(ttl)
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "could not encode cache entry")
+	}
+
+	// Write to a temporary file first so concurrent readers never observe a
+	// partially written entry.
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "could not write cache entry")
+	}
+
+	if _, err := tmp.Write(buf); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrap(err, "could not write cache entry")
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrap(err, "could not write cache entry")
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrap(err, "could not write cache entry")
+	}
+
+	return nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
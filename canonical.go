@@ -0,0 +1,204 @@
+package llmberjack
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/invopop/jsonschema"
+	"github.com/samber/lo"
+)
+
+// canonicalRequest is a provider-agnostic representation of the content of a
+// request, used to identify identical requests.
+type canonicalRequest struct {
+	Provider          string                                     `json:"provider,omitempty"`
+	Model             string                                     `json:"model,omitempty"`
+	Messages          []canonicalMessage                         `json:"messages"`
+	Schema            *jsonschema.Schema                         `json:"schema,omitempty"`
+	SchemaName        string                                     `json:"schema_name,omitempty"`
+	SchemaDescription string                                     `json:"schema_description,omitempty"`
+	Tools             []canonicalTool                            `json:"tools,omitempty"`
+	MaxTokens         *int                                       `json:"max_tokens,omitempty"`
+	MaxCandidates     *int                                       `json:"max_candidates,omitempty"`
+	Temperature       *float64                                   `json:"temperature,omitempty"`
+	TopP              *float64                                   `json:"top_p,omitempty"`
+	StopSequences     []string                                   `json:"stop_sequences,omitempty"`
+	Seed              *int                                       `json:"seed,omitempty"`
+	PresencePenalty   *float64                                   `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64                                   `json:"frequency_penalty,omitempty"`
+	Logprobs          *int                                       `json:"logprobs,omitempty"`
+	Thinking          *bool                                      `json:"thinking,omitempty"`
+	ProviderOptions   map[string]internal.ProviderRequestOptions `json:"provider_options,omitempty"`
+}
+
+type canonicalMessage struct {
+	Type     MessageType       `json:"type"`
+	Role     MessageRole       `json:"role"`
+	Parts    []string          `json:"parts"`
+	MimeType string            `json:"mime_type,omitempty"`
+	Url      string            `json:"url,omitempty"`
+	Filename string            `json:"filename,omitempty"`
+	Tool     *ResponseToolCall `json:"tool,omitempty"`
+}
+
+type canonicalTool struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Parameters  jsonschema.Schema `json:"parameters"`
+}
+
+// newCanonicalRequest builds the canonical representation of a request sent to
+// the given provider and model.
+//
+// It returns false if some of the message parts cannot be read without being
+// consumed.
+func newCanonicalRequest(providerName, model string, r innerRequest) (*canonicalRequest, bool) {
+	c := canonicalRequest{
+		Provider:          providerName,
+		Model:             model,
+		Messages:          make([]canonicalMessage, 0, len(r.Messages)),
+		Schema:            lo.CoalesceOrEmpty(r.SchemaOverride, r.ResponseSchema),
+		SchemaName:        r.SchemaName,
+		SchemaDescription: r.SchemaDescription,
+		Tools:             make([]canonicalTool, 0, len(r.Tools)),
+		MaxTokens:         r.MaxTokens,
+		MaxCandidates:     r.MaxCandidates,
+		Temperature:       r.Temperature,
+		TopP:              r.TopP,
+		StopSequences:     r.StopSequences,
+		Seed:              r.Seed,
+		PresencePenalty:   r.PresencePenalty,
+		FrequencyPenalty:  r.FrequencyPenalty,
+		Logprobs:          r.Logprobs,
+		Thinking:          r.Thinking,
+		ProviderOptions:   make(map[string]internal.ProviderRequestOptions, len(r.ProviderOptions)),
+	}
+
+	for _, msg := range r.Messages {
+		m := canonicalMessage{
+			Type:     msg.Type,
+			Role:     msg.Role,
+			Parts:    make([]string, 0, len(msg.Parts)),
+			MimeType: msg.MimeType,
+			Url:      msg.Url,
+			Filename: msg.Filename,
+			Tool:     msg.Tool,
+		}
+
+		for _, part := range msg.Parts {
+			content, ok := peekPart(part)
+			if !ok {
+				return nil, false
+			}
+
+			// Binary content is not valid UTF-8, and would not survive being
+			// encoded to JSON, so it is represented by its digest.
+			if msg.Type != TypeText {
+				hash := sha256.Sum256([]byte(content))
+				content = "sha256:" + hex.EncodeToString(hash[:])
+			}
+
+			m.Parts = append(m.Parts, content)
+		}
+
+		c.Messages = append(c.Messages, m)
+	}
+
+	for _, tool := range r.Tools {
+		c.Tools = append(c.Tools, canonicalTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+
+	slices.SortFunc(c.Tools, func(a, b canonicalTool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for t, opts := range r.ProviderOptions {
+		c.ProviderOptions[typeName(t)] = opts
+	}
+
+	return &c, true
+}
+
+// hash returns a digest of the canonical request. Maps are encoded with sorted
+// keys, so it is deterministic.
+func (c *canonicalRequest) hash() (string, bool) {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return "", false
+	}
+
+	hash := sha256.Sum256(buf)
+
+	return hex.EncodeToString(hash[:]), true
+}
+
+// chainDigest derives a key from another one and the digest of what precedes
+// it, such as the history of a thread.
+func chainDigest(digest, key string) string {
+	hash := sha256.Sum256([]byte(digest + ":" + key))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// peekPart reads the content of a message part and rewinds it, so it can
+// still be read by the provider. Parts that cannot be rewound are not read.
+func peekPart(part io.Reader) (string, bool) {
+	seeker, ok := part.(io.ReadSeeker)
+	if !ok {
+		return "", false
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+
+	if _, err := io.Copy(&buf, seeker); err != nil {
+		return "", false
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return "", false
+	}
+
+	return buf.String(), true
+}
+
+// describePart returns the content of a text part, or a short description of
+// a binary part, for observability purposes.
+func describePart(msg Message, part io.Reader) (string, bool) {
+	content, ok := peekPart(part)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case msg.Type == TypeText:
+	case msg.Filename != "":
+		return fmt.Sprintf("<%s, %s, %d bytes>", msg.Filename, msg.MimeType, len(content)), true
+	default:
+		return fmt.Sprintf("<%s, %d bytes>", msg.MimeType, len(content)), true
+	}
+
+	return content, true
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.PkgPath() + "." + t.Name()
+}
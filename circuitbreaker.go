@@ -0,0 +1,251 @@
+package llmberjack
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCoolDown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned, wrapped in a `ProviderError` matching
+// `ErrProviderUnavailable`, when a request is rejected because the circuit
+// breaker of its provider is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker of a provider.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests, until the cool-down elapsed.
+	CircuitOpen
+	// CircuitHalfOpen lets one trial request through at a time, to determine
+	// whether the provider recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// CircuitBreaker configures the circuit breakers protecting each provider.
+//
+// After a number of consecutive failures, the circuit of a provider opens and
+// requests to it are immediately rejected. Once the cool-down elapsed, trial
+// requests are let through, closing the circuit if they succeed, or opening it
+// again if they fail.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit. Defaults to 5.
+	FailureThreshold int
+	// SuccessThreshold is the number of successful trial requests required to
+	// close the circuit. Defaults to 1.
+	SuccessThreshold int
+	// CoolDown is how long the circuit stays open before trial requests are
+	// let through. Defaults to 30s.
+	CoolDown time.Duration
+
+	// IsFailure decides whether an error counts as a failure of the provider.
+	// If not provided, only errors matching `ErrProviderUnavailable` do.
+	IsFailure func(error) bool
+}
+
+type circuitBreaker struct {
+	mtx    sync.Mutex
+	config CircuitBreaker
+	now    func() time.Time
+
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(config CircuitBreaker) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaultCoolDown
+	}
+
+	return &circuitBreaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// transition moves an open circuit to half-open once the cool-down elapsed.
+// Must be called with the lock held.
+func (b *circuitBreaker) transition() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.CoolDown {
+		b.state = CircuitHalfOpen
+		b.successes = 0
+		b.trial = false
+	}
+}
+
+func (b *circuitBreaker) State() CircuitState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.transition()
+
+	return b.state
+}
+
+// allow returns whether a request can be sent, and whether it is a trial
+// request.
+func (b *circuitBreaker) allow() (bool, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.transition()
+
+	switch b.state {
+	case CircuitClosed:
+		return true, false
+	case CircuitHalfOpen:
+		if b.trial {
+			return false, false
+		}
+
+		b.trial = true
+
+		return true, true
+	}
+
+	return false, false
+}
+
+func (b *circuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if b.config.IsFailure != nil {
+		return b.config.IsFailure(err)
+	}
+
+	return errors.Is(err, ErrProviderUnavailable)
+}
+
+// record updates the state of the circuit from the outcome of a request.
+// Errors that are not failures of the provider do not affect the state.
+func (b *circuitBreaker) record(trial bool, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	failure := b.isFailure(err)
+
+	switch b.state {
+	case CircuitClosed:
+		switch {
+		case failure:
+			b.failures += 1
+
+			if b.failures >= b.config.FailureThreshold {
+				b.open()
+			}
+		case err == nil:
+			b.failures = 0
+		}
+
+	case CircuitHalfOpen:
+		if !trial {
+			return
+		}
+
+		b.trial = false
+
+		switch {
+		case failure:
+			b.open()
+		case err == nil:
+			b.successes += 1
+
+			if b.successes >= b.config.SuccessThreshold {
+				b.state = CircuitClosed
+				b.failures = 0
+			}
+		}
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	b.trial = false
+}
+
+// CircuitState returns the state of the circuit breaker of the provider
+// registered with the given name.
+//
+// It returns false if circuit breaking is not enabled, or the provider is
+// unknown.
+func (llm *Llmberjack) CircuitState(providerName string) (CircuitState, bool) {
+	breaker, ok := llm.circuitBreakers[providerName]
+	if !ok {
+		return CircuitClosed, false
+	}
+
+	return breaker.State(), true
+}
+
+// CircuitStates returns the state of the circuit breakers of all providers,
+// keyed by their registered names. The provider set with `WithDefaultProvider()`
+// is named `DefaultProviderName`.
+func (llm *Llmberjack) CircuitStates() map[string]CircuitState {
+	states := make(map[string]CircuitState, len(llm.circuitBreakers))
+
+	for name, breaker := range llm.circuitBreakers {
+		states[name] = breaker.State()
+	}
+
+	return states
+}
+
+// breakCircuit wraps a handler to reject invocations while the circuit
+// breaker of their provider is open.
+func (llm *Llmberjack) breakCircuit(next Handler) Handler {
+	return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+		breaker, ok := llm.circuitBreakers[inv.ProviderName]
+		if !ok {
+			return next(ctx, inv)
+		}
+
+		allowed, trial := breaker.allow()
+		if !allowed {
+			return nil, &ProviderError{
+				Provider: inv.ProviderName,
+				Kind:     ErrProviderUnavailable,
+				Err:      errors.Wrapf(ErrCircuitOpen, "provider '%s'", inv.ProviderName),
+			}
+		}
+
+		resp, err := next(ctx, inv)
+
+		breaker.record(trial, err)
+
+		return resp, err
+	}
+}
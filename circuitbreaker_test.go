@@ -0,0 +1,112 @@
+package llmberjack
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	now := time.Now()
+	unavailable := &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}
+	invalid := &ProviderError{StatusCode: http.StatusBadRequest, Err: errors.New("invalid")}
+
+	b := newCircuitBreaker(CircuitBreaker{FailureThreshold: 2, CoolDown: time.Minute})
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, CircuitClosed, b.State())
+
+	b.record(false, unavailable)
+	b.record(false, nil)
+	b.record(false, unavailable)
+	b.record(false, invalid)
+
+	assert.Equal(t, CircuitClosed, b.State())
+
+	b.record(false, unavailable)
+
+	assert.Equal(t, CircuitOpen, b.State())
+
+	allowed, _ := b.allow()
+
+	assert.False(t, allowed)
+
+	now = now.Add(time.Minute)
+
+	assert.Equal(t, CircuitHalfOpen, b.State())
+
+	allowed, trial := b.allow()
+
+	assert.True(t, allowed)
+	assert.True(t, trial)
+
+	// Only one trial request is let through at a time.
+	allowed, _ = b.allow()
+
+	assert.False(t, allowed)
+
+	b.record(true, unavailable)
+
+	assert.Equal(t, CircuitOpen, b.State())
+
+	now = now.Add(time.Minute)
+	_, trial = b.allow()
+
+	b.record(trial, nil)
+
+	assert.Equal(t, CircuitClosed, b.State())
+}
+
+func TestCircuitBreakerProvider(t *testing.T) {
+	p1 := NewMockProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithProvider("p1", p1),
+		WithProvider("p2", p2),
+		WithCircuitBreaker(CircuitBreaker{FailureThreshold: 2, CoolDown: time.Hour}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}),
+	)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	assert.Equal(t, map[string]CircuitState{"p1": CircuitClosed, "p2": CircuitClosed}, llm.CircuitStates())
+
+	withDefault, _ := New(
+		WithDefaultProvider(p1),
+		WithProvider("p2", p2),
+		WithCircuitBreaker(CircuitBreaker{FailureThreshold: 2, CoolDown: time.Hour}),
+	)
+
+	assert.Equal(t, map[string]CircuitState{DefaultProviderName: CircuitClosed, "p2": CircuitClosed}, withDefault.CircuitStates())
+
+	_, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// The circuit opened after two attempts, the remaining ones were not retried.
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 2)
+
+	state, ok := llm.CircuitState("p1")
+
+	assert.True(t, ok)
+	assert.Equal(t, CircuitOpen, state)
+
+	resp, err := NewUntypedRequest().WithFallbackProviders("p2").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 2)
+
+	_, ok = llm.CircuitState("unknown")
+
+	assert.False(t, ok)
+}
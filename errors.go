@@ -0,0 +1,130 @@
+package llmberjack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Errors representing the kind of failure a provider encountered. Errors
+// returned by providers can be checked against them with `errors.Is()`.
+var (
+	// ErrRateLimited is returned when the provider rejected the request because
+	// a rate limit or quota was exceeded.
+	ErrRateLimited = errors.New("rate limited by provider")
+	// ErrAuthentication is returned when the provider rejected the credentials,
+	// or they do not grant access to the requested resource.
+	ErrAuthentication = errors.New("provider authentication failed")
+	// ErrContextLengthExceeded is returned when the request does not fit in the
+	// context window of the model.
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	// ErrContentFiltered is returned when the provider refused to process the
+	// request because of its content policy.
+	ErrContentFiltered = errors.New("content was filtered by provider")
+	// ErrInvalidRequest is returned when the provider rejected the request as
+	// malformed or unsupported.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrProviderUnavailable is returned when the provider could not be reached
+	// or failed to process the request on its end.
+	ErrProviderUnavailable = errors.New("provider unavailable")
+)
+
+// ErrUnsupportedContent is returned, before sending a request, when it contains
+// content the provider cannot accept, such as a file of a MIME type it does not
+// support.
+var ErrUnsupportedContent = errors.New("content not supported by provider")
+
+// ErrUnsupportedOption is returned, before sending a request, when it uses a
+// setting, or a combination of settings, the provider does not support.
+var ErrUnsupportedOption = errors.New("option not supported by provider")
+
+// ProviderError is returned by providers when the underlying API call failed.
+//
+// It carries details about the failure, and the information required to decide
+// whether, and when, the call can be retried. It matches one of the `Err*`
+// sentinel errors through `errors.Is()`.
+type ProviderError struct {
+	// Provider identifies the API that returned the error.
+	Provider string
+	// StatusCode is the HTTP status code returned by the provider, if any. It
+	// is zero if the call failed before a response was received.
+	StatusCode int
+	// Code is the provider-specific error code or status, if any.
+	Code string
+	// Message is the error message returned by the provider, if any.
+	Message string
+	// RetryAfter is the delay the provider asked to wait before retrying, if
+	// any.
+	RetryAfter time.Duration
+	// Kind is the sentinel error representing the failure. If not set, it is
+	// derived from the status code.
+	Kind error
+
+	Err error
+}
+
+// Error returns the message of the underlying error, falling back to the
+// message or status code returned by the provider.
+func (e *ProviderError) Error() string {
+	switch {
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.Message != "":
+		return e.Message
+	case e.StatusCode != 0:
+		return fmt.Sprintf("provider responded with status %d", e.StatusCode)
+	}
+
+	return "provider error"
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches a provider error against the sentinel error representing its
+// kind.
+func (e *ProviderError) Is(target error) bool {
+	kind := e.kind()
+
+	return kind != nil && kind == target
+}
+
+func (e *ProviderError) kind() error {
+	if e.Kind != nil {
+		return e.Kind
+	}
+
+	if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+		return nil
+	}
+
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrAuthentication
+	case e.StatusCode == 0, e.StatusCode == http.StatusRequestTimeout, e.StatusCode >= 500:
+		return ErrProviderUnavailable
+	case e.StatusCode >= 400:
+		return ErrInvalidRequest
+	}
+
+	return nil
+}
+
+// IsRetryable returns whether an error returned by a provider is transient,
+// and the request could succeed if sent again.
+//
+// Rate limits and unavailable providers (server errors and network failures)
+// are considered retryable, but not context cancellations.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrProviderUnavailable)
+}
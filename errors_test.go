@@ -0,0 +1,73 @@
+package llmberjack
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderErrorKind(t *testing.T) {
+	tests := []struct {
+		err      *ProviderError
+		expected error
+	}{
+		{&ProviderError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited},
+		{&ProviderError{StatusCode: http.StatusUnauthorized}, ErrAuthentication},
+		{&ProviderError{StatusCode: http.StatusForbidden}, ErrAuthentication},
+		{&ProviderError{StatusCode: http.StatusBadRequest}, ErrInvalidRequest},
+		{&ProviderError{StatusCode: http.StatusNotFound}, ErrInvalidRequest},
+		{&ProviderError{StatusCode: http.StatusInternalServerError}, ErrProviderUnavailable},
+		{&ProviderError{StatusCode: http.StatusServiceUnavailable}, ErrProviderUnavailable},
+		{&ProviderError{StatusCode: http.StatusRequestTimeout}, ErrProviderUnavailable},
+		{&ProviderError{}, ErrProviderUnavailable},
+		{&ProviderError{StatusCode: http.StatusBadRequest, Kind: ErrContextLengthExceeded}, ErrContextLengthExceeded},
+	}
+
+	sentinels := []error{ErrRateLimited, ErrAuthentication, ErrContextLengthExceeded, ErrContentFiltered, ErrInvalidRequest, ErrProviderUnavailable}
+
+	for _, tt := range tests {
+		tt.err.Err = errors.New("provider error")
+
+		err := errors.Wrap(tt.err, "LLM provider failed to generate content")
+
+		for _, sentinel := range sentinels {
+			assert.Equal(t, sentinel == tt.expected, errors.Is(err, sentinel), "status %d, sentinel %s", tt.err.StatusCode, sentinel)
+		}
+	}
+
+	err := &ProviderError{Err: context.Canceled}
+
+	for _, sentinel := range sentinels {
+		assert.False(t, errors.Is(err, sentinel))
+	}
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestProviderErrorMessage(t *testing.T) {
+	tests := []struct {
+		err      *ProviderError
+		expected string
+	}{
+		{&ProviderError{StatusCode: http.StatusBadRequest, Message: "bad request", Err: errors.New("wrapped")}, "wrapped"},
+		{&ProviderError{StatusCode: http.StatusBadRequest, Message: "bad request"}, "bad request"},
+		{&ProviderError{StatusCode: http.StatusBadRequest}, "provider responded with status 400"},
+		{&ProviderError{}, "provider error"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.err.Error())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&ProviderError{StatusCode: http.StatusTooManyRequests, Err: errors.New("error")}))
+	assert.True(t, IsRetryable(errors.Wrap(&ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("error")}, "wrapped")))
+	assert.True(t, IsRetryable(&ProviderError{Err: errors.New("connection reset")}))
+	assert.False(t, IsRetryable(&ProviderError{StatusCode: http.StatusBadRequest, Err: errors.New("error")}))
+	assert.False(t, IsRetryable(&ProviderError{Err: context.Canceled}))
+	assert.False(t, IsRetryable(errors.New("error")))
+}
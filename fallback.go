@@ -0,0 +1,72 @@
+package llmberjack
+
+import (
+	"slices"
+
+	"github.com/cockroachdb/errors"
+)
+
+// WithFallbackProviders sets the providers to try, in order, if the selected
+// provider fails with a retryable error (after the retry policy is exhausted).
+// It overrides the fallback providers configured on the adapter.
+//
+// When a model is set on the request with `WithModel()`, it is used for all
+// providers. To select a model depending on the provider, use
+// `WithModelFunc()`, which is called for every provider being tried.
+//
+// Fallbacks are not used for requests continuing an existing thread, since a
+// thread is bound to the provider that created it. A request creating a
+// thread will create it on the provider that successfully responded.
+//
+// Example usage:
+//
+//	resp, err := llmberjack.NewUntypedRequest().
+//		WithProvider("vertex").
+//		WithFallbackProviders("openai").
+//		WithModelFunc(func(_ llmberjack.Llm, name *string) string {
+//			if name != nil && *name == "openai" {
+//				return "gpt-4.1"
+//			}
+//			return "gemini-2.5-pro"
+//		}).
+//		Do(ctx, llm)
+func (r Request[T]) WithFallbackProviders(names ...string) Request[T] {
+	r.fallbackProviders = names
+
+	return r
+}
+
+// providerChain returns the names of the providers to try, in order, starting
+// with the selected one. A nil name represents the default provider.
+//
+// Fallback providers are validated up front, so an unknown one is reported
+// before any provider is tried.
+func (r Request[T]) providerChain(llm *Llmberjack) ([]*string, error) {
+	chain := []*string{r.provider}
+
+	if r.ThreadId != nil {
+		return chain, nil
+	}
+
+	fallbacks := r.fallbackProviders
+	if fallbacks == nil {
+		fallbacks = llm.fallbackProviders
+	}
+
+	tried := []string{llm.providerName(r.provider)}
+
+	for _, name := range fallbacks {
+		if _, ok := llm.providers[name]; !ok {
+			return nil, errors.Newf("unknown fallback provider '%s'", name)
+		}
+
+		if slices.Contains(tried, name) {
+			continue
+		}
+
+		tried = append(tried, name)
+		chain = append(chain, &name)
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,125 @@
+package llmberjack
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newFallbackProviders(t *testing.T) (*MockProvider, *MockProvider, *MockProvider) {
+	t.Helper()
+
+	providers := []*MockProvider{NewMockProvider(), NewMockProvider(), NewMockProvider()}
+
+	for _, p := range providers {
+		p.On("Init", mock.Anything).Return(nil)
+	}
+
+	return providers[0], providers[1], providers[2]
+}
+
+func TestFallbackProviders(t *testing.T) {
+	p1, p2, p3 := newFallbackProviders(t)
+
+	llm, _ := New(WithProvider("p1", p1), WithProvider("p2", p2), WithProvider("p3", p3))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusTooManyRequests, Err: errors.New("rate limited")})
+	p3.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	models := []string{}
+
+	resp, err := NewUntypedRequest().
+		CreateThread().
+		WithFallbackProviders("p2", "p3").
+		WithModelFunc(func(_ Llm, name *string) string {
+			models = append(models, "model-"+llm.providerName(name))
+
+			return "model-" + llm.providerName(name)
+		}).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	assert.Equal(t, []string{"model-p1", "model-p2", "model-p3"}, models)
+	assert.Equal(t, p3, resp.ThreadId.provider)
+
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 1)
+	p2.AssertNumberOfCalls(t, "ChatCompletion", 1)
+	p3.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestFallbackNonRetryable(t *testing.T) {
+	p1, p2, _ := newFallbackProviders(t)
+
+	llm, _ := New(WithProvider("p1", p1), WithProvider("p2", p2))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusUnauthorized, Err: errors.New("unauthorized")})
+
+	_, err := NewUntypedRequest().WithFallbackProviders("p2").Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrAuthentication)
+	p2.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFallbackExhausted(t *testing.T) {
+	p1, p2, _ := newFallbackProviders(t)
+
+	llm, _ := New(WithProvider("p1", p1), WithProvider("p2", p2), WithFallbackProviders("p1", "p2"))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("first unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("second unavailable")})
+
+	_, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "second unavailable")
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+
+	// The default provider is not tried twice.
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 1)
+	p2.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestFallbackInThread(t *testing.T) {
+	p1, p2, _ := newFallbackProviders(t)
+
+	llm, _ := New(WithProvider("p1", p1), WithProvider("p2", p2), WithFallbackProviders("p2"))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil).Once()
+
+	resp, err := NewUntypedRequest().CreateThread().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+
+	_, err = NewUntypedRequest().FromCandidate(resp, 0).Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+	p2.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnknownRequestFallbackProvider(t *testing.T) {
+	p1, _, _ := newFallbackProviders(t)
+
+	llm, _ := New(WithProvider("p1", p1))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+
+	_, err := NewUntypedRequest().WithFallbackProviders("unknown").Do(t.Context(), llm)
+
+	// Fallback providers are validated before any provider is tried.
+	assert.ErrorContains(t, err, "unknown fallback provider 'unknown'")
+	p1.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnknownFallbackProvider(t *testing.T) {
+	p1, _, _ := newFallbackProviders(t)
+
+	_, err := New(WithProvider("p1", p1), WithFallbackProviders("unknown"))
+
+	assert.ErrorContains(t, err, "unknown fallback provider 'unknown'")
+}
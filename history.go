@@ -13,10 +13,10 @@ type History[T any] struct {
 	history map[*ThreadId][]T
 }
 
-// Save appends a new message to the conversation history.
-// The `message` parameter should be of the generic type `T`, matching the
+// Save appends new messages to the conversation history.
+// The `messages` parameters should be of the generic type `T`, matching the
 // content representation expected by the LLM provider.
-func (h *History[T]) Save(threadId *ThreadId, message T) {
+func (h *History[T]) Save(threadId *ThreadId, messages ...T) {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
@@ -27,7 +27,7 @@ func (h *History[T]) Save(threadId *ThreadId, message T) {
 		h.history[threadId] = make([]T, 0)
 	}
 
-	h.history[threadId] = append(h.history[threadId], message)
+	h.history[threadId] = append(h.history[threadId], messages...)
 }
 
 // Load retrieves the entire conversation history as a slice of messages.
@@ -85,3 +85,51 @@ func (h *History[T]) Copy(threadId *ThreadId) *ThreadId {
 
 	return newThreadId
 }
+
+// Describe describes the messages of the history of a thread, in order, with
+// the provided function. It is used by providers to implement
+// `HistoryCompactor`.
+func (h *History[T]) Describe(threadId *ThreadId, describe func(T) HistoryMessage) []HistoryMessage {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	messages := make([]HistoryMessage, len(h.history[threadId]))
+
+	for idx, msg := range h.history[threadId] {
+		messages[idx] = describe(msg)
+	}
+
+	return messages
+}
+
+// Compact removes the `count` oldest messages of the history of a thread for
+// which `pinned` returns false, and inserts the replacement messages where the
+// first one was. Pinned messages, such as system instructions, are kept in
+// place.
+func (h *History[T]) Compact(threadId *ThreadId, count int, pinned func(T) bool, replacement ...T) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.history == nil {
+		return
+	}
+
+	compacted := make([]T, 0, len(h.history[threadId]))
+
+	for _, msg := range h.history[threadId] {
+		if count > 0 && !pinned(msg) {
+			count -= 1
+
+			if replacement != nil {
+				compacted = append(compacted, replacement...)
+				replacement = nil
+			}
+
+			continue
+		}
+
+		compacted = append(compacted, msg)
+	}
+
+	h.history[threadId] = compacted
+}
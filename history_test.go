@@ -71,3 +71,22 @@ func TestCopyCloseThread(t *testing.T) {
 	assert.Len(t, h.Load(t1), 2)
 	assert.Len(t, h.Load(t2), 1)
 }
+
+func TestCompactHistory(t *testing.T) {
+	h := History[int]{}
+
+	threadId := &ThreadId{}
+	pinned := func(msg int) bool { return msg < 0 }
+
+	h.Save(threadId, -1, 1, 2, -2, 3, 4)
+	h.Compact(threadId, 3, pinned, 10)
+
+	assert.Equal(t, []int{-1, 10, -2, 4}, h.Load(threadId))
+
+	h.Compact(threadId, 1, pinned)
+
+	assert.Equal(t, []int{-1, -2, 4}, h.Load(threadId))
+	assert.Equal(t, []HistoryMessage{{Tokens: -1}, {Tokens: -2}, {Tokens: 4}}, h.Describe(threadId, func(msg int) HistoryMessage {
+		return HistoryMessage{Tokens: msg}
+	}))
+}
@@ -0,0 +1,178 @@
+package llmberjack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const defaultSummaryInstruction = "Summarize the following conversation between a user and an AI assistant, " +
+	"including the tools it called and their results. Keep all facts, identifiers, decisions and open questions " +
+	"needed to continue the conversation. Only reply with the summary."
+
+// HistoryPolicy bounds the history of a thread, to keep it within the context
+// window of the model. Zero values are not enforced.
+//
+// The history is split in turns, each starting with a user message, and the
+// oldest turns are removed from it before a request is sent in the thread, until
+// it satisfies the policy. System instructions are always preserved, and a turn
+// is never split, so tool calls are kept with their responses. The most recent
+// turn is always kept, even if it exceeds the limits.
+type HistoryPolicy struct {
+	// MaxTurns is the maximum number of previous turns kept in the history
+	// sent with a request.
+	MaxTurns int
+	// MaxTokens is the maximum number of tokens of the history, including
+	// system instructions. It is estimated by the provider, without any
+	// network call.
+	MaxTokens int
+
+	// Summarizer, if set, replaces the removed turns by a summary instead of
+	// dropping them.
+	Summarizer *HistorySummarizer
+}
+
+// HistorySummarizer configures how turns removed from the history of a thread
+// are summarized.
+//
+// The summary is generated by a request sent outside of the thread, which can
+// use a different, cheaper, provider and model. It is added to the history as a
+// user message, and is itself summarized with the next removed turns.
+type HistorySummarizer struct {
+	// Provider is the name of the provider generating the summary. Defaults to
+	// the default provider.
+	Provider string
+	// Model is the model generating the summary. Defaults to the default model
+	// of the provider.
+	Model string
+	// Instruction overrides the system prompt asking for the summary.
+	Instruction string
+}
+
+// HistoryMessage describes a message of the history of a thread, for history
+// policies to decide which messages to remove.
+type HistoryMessage struct {
+	Role MessageRole
+	// Text is the textual content of the message, including tool calls and
+	// responses, used to summarize it.
+	Text string
+	// Tokens is the estimated number of tokens of the message.
+	Tokens int
+}
+
+// compactHistory applies the history policy of a thread, if any, to its history
+// on the provider.
+func (llm *Llmberjack) compactHistory(ctx context.Context, provider Llm, threadId *ThreadId) error {
+	if threadId == nil {
+		return nil
+	}
+
+	policy := threadId.policy.Load()
+	if policy == nil {
+		return nil
+	}
+
+	compactor, ok := supports[HistoryCompactor](provider)
+	if !ok {
+		return errors.New("provider does not support history policies")
+	}
+
+	messages := compactor.ThreadHistory(threadId)
+	removed := policy.removed(messages)
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	var summary string
+
+	if policy.Summarizer != nil {
+		text, err := llm.summarize(ctx, *policy.Summarizer, removed)
+		if err != nil {
+			return errors.Wrap(err, "could not summarize thread history")
+		}
+
+		summary = "Summary of the earlier conversation:\n\n" + text
+	}
+
+	compactor.CompactThread(threadId, len(removed), summary)
+
+	llm.log(ctx, slog.LevelDebug, "compacted thread history", threadAttr(threadId), slog.Int("removed_messages", len(removed)), slog.Bool("summarized", summary != ""))
+
+	return nil
+}
+
+// removed returns the oldest messages, excluding system instructions, to remove
+// from the history for it to satisfy the policy.
+func (p HistoryPolicy) removed(messages []HistoryMessage) []HistoryMessage {
+	tokens := 0
+	turns := make([][]HistoryMessage, 0)
+
+	for _, msg := range messages {
+		tokens += msg.Tokens
+
+		if msg.Role == RoleSystem {
+			continue
+		}
+		if msg.Role == RoleUser || len(turns) == 0 {
+			turns = append(turns, make([]HistoryMessage, 0, 1))
+		}
+
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+
+	removed := make([]HistoryMessage, 0)
+
+	for len(turns) > 1 {
+		exceedsTurns := p.MaxTurns > 0 && len(turns) > p.MaxTurns
+		exceedsTokens := p.MaxTokens > 0 && tokens > p.MaxTokens
+
+		if !exceedsTurns && !exceedsTokens {
+			break
+		}
+
+		for _, msg := range turns[0] {
+			tokens -= msg.Tokens
+		}
+
+		removed = append(removed, turns[0]...)
+		turns = turns[1:]
+	}
+
+	return removed
+}
+
+// summarize asks a model for a summary of the provided messages.
+func (llm *Llmberjack) summarize(ctx context.Context, summarizer HistorySummarizer, messages []HistoryMessage) (string, error) {
+	var transcript strings.Builder
+
+	for _, msg := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", roleName(msg.Role), msg.Text)
+	}
+
+	instruction := summarizer.Instruction
+	if instruction == "" {
+		instruction = defaultSummaryInstruction
+	}
+
+	req := NewUntypedRequest().
+		WithInstruction(instruction).
+		WithText(RoleUser, transcript.String())
+
+	if summarizer.Provider != "" {
+		req = req.WithProvider(summarizer.Provider)
+	}
+	if summarizer.Model != "" {
+		req = req.WithModel(summarizer.Model)
+	}
+
+	resp, err := req.Do(ctx, llm)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Get(0)
+}
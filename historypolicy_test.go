@@ -0,0 +1,61 @@
+package llmberjack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHistoryPolicyRemoved(t *testing.T) {
+	messages := []HistoryMessage{
+		{Role: RoleSystem, Text: "instruction", Tokens: 10},
+		{Role: RoleUser, Text: "first", Tokens: 10},
+		{Role: RoleAi, Text: "[tool call]", Tokens: 10},
+		{Role: RoleTool, Text: "tool output", Tokens: 10},
+		{Role: RoleAi, Text: "first reply", Tokens: 10},
+		{Role: RoleUser, Text: "second", Tokens: 10},
+		{Role: RoleAi, Text: "second reply", Tokens: 10},
+		{Role: RoleUser, Text: "third", Tokens: 10},
+	}
+
+	tests := []struct {
+		policy  HistoryPolicy
+		removed int
+	}{
+		{HistoryPolicy{}, 0},
+		{HistoryPolicy{MaxTurns: 3}, 0},
+		{HistoryPolicy{MaxTurns: 2}, 4},
+		{HistoryPolicy{MaxTurns: 1}, 6},
+		// The most recent turn is always kept.
+		{HistoryPolicy{MaxTurns: -1, MaxTokens: 1}, 6},
+		{HistoryPolicy{MaxTokens: 80}, 0},
+		// Turns with tool calls are removed whole.
+		{HistoryPolicy{MaxTokens: 70}, 4},
+		{HistoryPolicy{MaxTokens: 40}, 4},
+		{HistoryPolicy{MaxTokens: 30}, 6},
+		{HistoryPolicy{MaxTurns: 2, MaxTokens: 30}, 6},
+	}
+
+	for _, tt := range tests {
+		removed := tt.policy.removed(messages)
+
+		assert.Len(t, removed, tt.removed)
+		assert.NotContains(t, removed, messages[0])
+	}
+}
+
+func TestHistoryPolicyUnsupported(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	threadId := &ThreadId{provider: p}
+	threadId.SetHistoryPolicy(HistoryPolicy{MaxTurns: 1})
+
+	_, err := NewUntypedRequest().InThread(threadId).Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "provider does not support history policies")
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"sync"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+type modelTokenizer struct {
+	codec tokenizer.Codec
+	exact bool
+}
+
+var (
+	tokenizersMtx sync.Mutex
+	tokenizers    = make(map[string]modelTokenizer)
+)
+
+// Tokenizer returns the BPE tokenizer of an OpenAI model, picked from its name.
+//
+// Models whose encoding is unknown are tokenized with `o200k_base`, the
+// encoding of the most recent models, in which case the second return value is
+// false.
+func Tokenizer(model string) (tokenizer.Codec, bool) {
+	tokenizersMtx.Lock()
+	defer tokenizersMtx.Unlock()
+
+	if t, ok := tokenizers[model]; ok {
+		return t.codec, t.exact
+	}
+
+	t := modelTokenizer{exact: true}
+
+	codec, err := tokenizer.ForModel(tokenizer.Model(model))
+	if err != nil {
+		codec, _ = tokenizer.Get(tokenizer.O200kBase)
+		t.exact = false
+	}
+
+	t.codec = codec
+	tokenizers[model] = t
+
+	return t.codec, t.exact
+}
+
+// CountTokens returns the number of tokens a text is encoded into by a BPE
+// tokenizer.
+func CountTokens(codec tokenizer.Codec, text string) int {
+	count, err := codec.Count(text)
+	if err != nil {
+		return 0
+	}
+
+	return count
+}
+
+// EstimateTokens estimates the number of tokens of a text for models whose
+// tokenizer is not available, using the `o200k_base` encoding.
+func EstimateTokens(text string) int {
+	codec, _ := Tokenizer("")
+
+	return CountTokens(codec, text)
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+func TestTokenizer(t *testing.T) {
+	tts := []struct {
+		model    string
+		encoding tokenizer.Encoding
+		exact    bool
+	}{
+		{"gpt-4o", tokenizer.O200kBase, true},
+		{"gpt-4o-mini", tokenizer.O200kBase, true},
+		{"gpt-4.1-2025-04-14", tokenizer.O200kBase, true},
+		{"gpt-4", tokenizer.Cl100kBase, true},
+		{"gpt-3.5-turbo", tokenizer.Cl100kBase, true},
+		{"unknown-model", tokenizer.O200kBase, false},
+		{"", tokenizer.O200kBase, false},
+	}
+
+	for _, tt := range tts {
+		codec, exact := Tokenizer(tt.model)
+
+		assert.Equal(t, string(tt.encoding), codec.GetName(), tt.model)
+		assert.Equal(t, tt.exact, exact, tt.model)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	tts := []struct {
+		model  string
+		text   string
+		tokens []uint
+	}{
+		{"gpt-4", "hello world", []uint{15339, 1917}},
+		{"gpt-4", "tiktoken is great!", []uint{83, 1609, 5963, 374, 2294, 0}},
+		{"gpt-4o", "hello world", []uint{24912, 2375}},
+		{"gpt-4o", "tiktoken is great!", []uint{83, 8251, 2488, 382, 2212, 0}},
+	}
+
+	for _, tt := range tts {
+		codec, _ := Tokenizer(tt.model)
+		ids, _, err := codec.Encode(tt.text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt.tokens, ids, tt.model)
+		assert.Equal(t, len(tt.tokens), CountTokens(codec, tt.text), tt.model)
+	}
+
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 2, EstimateTokens("hello world"))
+}
@@ -0,0 +1,530 @@
+// Package llmberjacktest provides a scriptable fake LLM provider, to unit test
+// code using llmberjack without calling any actual provider.
+//
+// Example usage:
+//
+//	p := llmberjacktest.NewProvider()
+//	p.Enqueue(
+//		llmberjacktest.ToolCalls(llmberjacktest.ToolCall("get_weather", WeatherParams{City: "Paris"})),
+//		llmberjacktest.JSON(Output{Reply: "It is sunny."}),
+//	)
+//
+//	llm, _ := llmberjack.New(llmberjack.WithProvider("openai", p))
+//
+//	// ... exercise the code under test ...
+//
+//	calls := p.Calls()
+package llmberjacktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+)
+
+// ErrNoReply is returned by the fake provider when it receives a request
+// while no reply was queued.
+var ErrNoReply = errors.New("no reply queued on fake provider")
+
+// Reply is a scripted response from the fake provider.
+type Reply struct {
+	Candidates []llmberjack.ResponseCandidate
+	Usage      llmberjack.Usage
+	// Err, if set, is returned instead of the response.
+	Err error
+	// Latency is how long the provider waits before replying.
+	Latency time.Duration
+}
+
+// Text creates a reply with a single candidate containing the provided text.
+func Text(text string) Reply {
+	return Reply{
+		Candidates: []llmberjack.ResponseCandidate{
+			{Text: text, FinishReason: llmberjack.FinishReasonStop},
+		},
+	}
+}
+
+// JSON creates a reply with a single candidate containing the JSON
+// representation of the provided value, to be used as the output of typed
+// requests.
+//
+// It panics if the value cannot be serialized.
+func JSON(output any) Reply {
+	buf, err := json.Marshal(output)
+	if err != nil {
+		panic(fmt.Sprintf("llmberjacktest: could not serialize JSON fixture: %v", err))
+	}
+
+	return Text(string(buf))
+}
+
+// ToolCalls creates a reply with a single candidate requesting the provided
+// tools to be called.
+func ToolCalls(calls ...llmberjack.ResponseToolCall) Reply {
+	return Reply{
+		Candidates: []llmberjack.ResponseCandidate{
+			{ToolCalls: calls, FinishReason: llmberjack.FinishReasonStop},
+		},
+	}
+}
+
+// ToolCall creates a tool call to the tool with the provided name, and
+// arguments serialized to JSON. The fake provider will assign it an
+// identifier.
+//
+// It panics if the arguments cannot be serialized.
+func ToolCall(name string, args any) llmberjack.ResponseToolCall {
+	buf, err := json.Marshal(args)
+	if err != nil {
+		panic(fmt.Sprintf("llmberjacktest: could not serialize tool arguments: %v", err))
+	}
+
+	return llmberjack.ResponseToolCall{Name: name, Parameters: buf}
+}
+
+// Error creates a reply failing with the provided error.
+func Error(err error) Reply {
+	return Reply{Err: err}
+}
+
+// WithLatency returns a copy of the reply, delayed by the provided duration.
+func (r Reply) WithLatency(latency time.Duration) Reply {
+	r.Latency = latency
+
+	return r
+}
+
+// WithUsage returns a copy of the reply, reporting the provided token usage.
+func (r Reply) WithUsage(usage llmberjack.Usage) Reply {
+	r.Usage = usage
+
+	return r
+}
+
+// Message is a message received by, or replied by, the fake provider.
+type Message struct {
+	Role     llmberjack.MessageRole
+	Type     llmberjack.MessageType
+	Parts    []string
+	MimeType string
+	Url      string
+	Filename string
+	// Tool is the tool call this message responds to, or was requested by the
+	// provider.
+	Tool *llmberjack.ResponseToolCall
+}
+
+// Text returns the concatenation of all parts of the message.
+func (m Message) Text() string {
+	return strings.Join(m.Parts, "")
+}
+
+// Call is a request received by the fake provider.
+type Call struct {
+	// Provider is the name of the fake that received the request, if it was
+	// created from a `Providers` set.
+	Provider string
+	// Requester is the request as received by the provider. Its full content
+	// can be inspected with `Requester.ToRequest()`.
+	Requester llmberjack.Requester
+	ThreadId  *llmberjack.ThreadId
+	// Model is the requested model, or the default model of the adapter.
+	Model string
+	// Messages are the messages of the request, excluding the history of the
+	// thread.
+	Messages []Message
+}
+
+// Provider is a fake `llmberjack.Llm` replying with scripted responses, in the
+// order they were queued.
+//
+// Each registered provider has its own queue, so a fake should be registered
+// for each provider name the code under test uses, which `Providers` helps
+// with.
+type Provider struct {
+	mtx     sync.Mutex
+	name    string
+	set     *Providers
+	replies []Reply
+	calls   []Call
+	latency time.Duration
+	seq     int
+
+	history llmberjack.History[Message]
+}
+
+// NewProvider creates a fake provider with no queued reply.
+func NewProvider() *Provider {
+	return &Provider{}
+}
+
+// Providers is a set of fake providers, one per provider name, to script the
+// replies of each provider the code under test uses, such as to test fallbacks.
+//
+// Example usage:
+//
+//	fakes := llmberjacktest.NewProviders()
+//	fakes.Enqueue("vertex", llmberjacktest.Error(&llmberjack.ProviderError{StatusCode: 503}))
+//	fakes.Enqueue("openai", llmberjacktest.Text("Hello"))
+//
+//	llm, _ := llmberjack.New(
+//		llmberjack.WithProvider("vertex", fakes.Provider("vertex")),
+//		llmberjack.WithProvider("openai", fakes.Provider("openai")),
+//	)
+type Providers struct {
+	mtx       sync.Mutex
+	providers map[string]*Provider
+	calls     []Call
+}
+
+// NewProviders creates an empty set of fake providers.
+func NewProviders() *Providers {
+	return &Providers{providers: make(map[string]*Provider)}
+}
+
+// Provider returns the fake provider to register under a name, creating it if
+// needed.
+func (s *Providers) Provider(name string) *Provider {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	p, ok := s.providers[name]
+	if !ok {
+		p = &Provider{name: name, set: s}
+		s.providers[name] = p
+	}
+
+	return p
+}
+
+// Enqueue adds replies to be returned to the next requests sent to the
+// provider registered under a name, in order.
+func (s *Providers) Enqueue(name string, replies ...Reply) *Providers {
+	s.Provider(name).Enqueue(replies...)
+
+	return s
+}
+
+// Pending returns the number of queued replies that were not consumed yet, by
+// any provider of the set.
+func (s *Providers) Pending() int {
+	s.mtx.Lock()
+	providers := lo.Values(s.providers)
+	s.mtx.Unlock()
+
+	return lo.SumBy(providers, (*Provider).Pending)
+}
+
+// Calls returns all requests received by the providers of the set, in order.
+// The provider that received each of them is identified by `Call.Provider`.
+func (s *Providers) Calls() []Call {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// Enqueue adds replies to be returned to the next requests, in order.
+func (p *Provider) Enqueue(replies ...Reply) *Provider {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.replies = append(p.replies, replies...)
+
+	return p
+}
+
+// WithLatency delays all replies by the provided duration, in addition to
+// their own latency.
+func (p *Provider) WithLatency(latency time.Duration) *Provider {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.latency = latency
+
+	return p
+}
+
+// Pending returns the number of queued replies that were not consumed yet.
+func (p *Provider) Pending() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.replies)
+}
+
+// Calls returns all requests received by the provider, in order.
+func (p *Provider) Calls() []Call {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return append([]Call(nil), p.calls...)
+}
+
+// LastCall returns the last request received by the provider.
+func (p *Provider) LastCall() (Call, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.calls) == 0 {
+		return Call{}, false
+	}
+
+	return p.calls[len(p.calls)-1], true
+}
+
+// History returns the messages recorded in a thread: the inputs of successful
+// requests, and the selected candidates.
+func (p *Provider) History(threadId *llmberjack.ThreadId) []Message {
+	return p.history.Load(threadId)
+}
+
+func (p *Provider) Init(llm internal.Adapter) error {
+	return nil
+}
+
+func (p *Provider) ResetThread(threadId *llmberjack.ThreadId) {
+	p.history.Clear(threadId)
+}
+
+func (p *Provider) CopyThread(threadId *llmberjack.ThreadId) *llmberjack.ThreadId {
+	return p.history.Copy(threadId)
+}
+
+func (p *Provider) CloseThread(threadId *llmberjack.ThreadId) {
+	p.history.Close(threadId)
+}
+
+// ThreadHistory describes the messages recorded in a thread, estimating their
+// tokens from their text.
+func (p *Provider) ThreadHistory(threadId *llmberjack.ThreadId) []llmberjack.HistoryMessage {
+	return p.history.Describe(threadId, func(msg Message) llmberjack.HistoryMessage {
+		text := msg.Text()
+
+		if msg.Tool != nil && msg.Role == llmberjack.RoleAi {
+			text = fmt.Sprintf("[tool call] %s(%s)", msg.Tool.Name, msg.Tool.Parameters)
+		}
+
+		return llmberjack.HistoryMessage{
+			Role:   msg.Role,
+			Text:   text,
+			Tokens: internal.EstimateTokens(text),
+		}
+	})
+}
+
+// CompactThread removes the oldest messages recorded in a thread, replacing
+// them with a user message containing the summary, if provided.
+func (p *Provider) CompactThread(threadId *llmberjack.ThreadId, count int, summary string) {
+	var replacement []Message
+
+	if summary != "" {
+		replacement = append(replacement, Message{Role: llmberjack.RoleUser, Type: llmberjack.TypeText, Parts: []string{summary}})
+	}
+
+	p.history.Compact(threadId, count, func(msg Message) bool {
+		return msg.Role == llmberjack.RoleSystem
+	}, replacement...)
+}
+
+// SaveInputs records the messages of a request in its thread, as if it was
+// sent, for responses served from the cache.
+func (p *Provider) SaveInputs(_ internal.Adapter, requester llmberjack.Requester) error {
+	req := requester.ToRequest()
+
+	if req.ThreadId == nil || req.SkipSaveInput {
+		return nil
+	}
+
+	messages, err := adaptMessages(req.Messages)
+	if err != nil {
+		return err
+	}
+
+	p.history.Save(req.ThreadId, messages...)
+
+	return nil
+}
+
+// SaveOutput records a candidate in the thread of a request, as if it was
+// selected, for responses served from the cache.
+func (p *Provider) SaveOutput(requester llmberjack.Requester, candidate llmberjack.ResponseCandidate) {
+	req := requester.ToRequest()
+
+	if req.ThreadId != nil && !req.SkipSaveOutput {
+		p.history.Save(req.ThreadId, outputMessages(candidate)...)
+	}
+}
+
+func (p *Provider) RequestOptionsType() reflect.Type {
+	return nil
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester) (*llmberjack.InnerResponse, error) {
+	return p.ChatCompletionStream(ctx, llm, requester, nil)
+}
+
+// ChatCompletionStream replies as `ChatCompletion` does, streaming the text of
+// each candidate word by word.
+func (p *Provider) ChatCompletionStream(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester, onDelta func(llmberjack.StreamDelta) bool) (*llmberjack.InnerResponse, error) {
+	req := requester.ToRequest()
+
+	call := Call{
+		Requester: requester,
+		ThreadId:  req.ThreadId,
+		Model:     lo.CoalesceOrEmpty(lo.FromPtr(req.Model), llm.DefaultModel()),
+	}
+
+	messages, err := adaptMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	call.Messages = messages
+
+	reply, latency, err := p.next(call)
+	if err != nil {
+		return nil, err
+	}
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(latency):
+		}
+	}
+
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+
+	resp := llmberjack.InnerResponse{
+		Id:         fmt.Sprintf("fake-%d", p.nextSeq()),
+		Model:      call.Model,
+		Candidates: make([]llmberjack.ResponseCandidate, len(reply.Candidates)),
+		Created:    time.Now(),
+		Usage:      reply.Usage,
+	}
+
+	for idx, candidate := range reply.Candidates {
+		candidate.ToolCalls = append([]llmberjack.ResponseToolCall(nil), candidate.ToolCalls...)
+
+		for toolIdx := range candidate.ToolCalls {
+			if candidate.ToolCalls[toolIdx].Id == "" {
+				candidate.ToolCalls[toolIdx].Id = fmt.Sprintf("call_%d", p.nextSeq())
+			}
+		}
+
+		if onDelta != nil {
+			for _, word := range strings.SplitAfter(candidate.Text, " ") {
+				if !onDelta(llmberjack.StreamDelta{Candidate: idx, Text: word}) {
+					return nil, llmberjack.ErrStreamStopped
+				}
+			}
+		}
+
+		candidate.SelectCandidate = func() {
+			if req.ThreadId != nil && !req.SkipSaveOutput {
+				p.history.Save(req.ThreadId, outputMessages(candidate)...)
+			}
+		}
+
+		resp.Candidates[idx] = candidate
+	}
+
+	if req.ThreadId != nil && !req.SkipSaveInput {
+		p.history.Save(req.ThreadId, call.Messages...)
+	}
+
+	return &resp, nil
+}
+
+func (p *Provider) next(call Call) (Reply, time.Duration, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	call.Provider = p.name
+	p.calls = append(p.calls, call)
+
+	if p.set != nil {
+		p.set.mtx.Lock()
+		p.set.calls = append(p.set.calls, call)
+		p.set.mtx.Unlock()
+	}
+
+	if len(p.replies) == 0 {
+		return Reply{}, 0, ErrNoReply
+	}
+
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+
+	return reply, p.latency + reply.Latency, nil
+}
+
+func (p *Provider) nextSeq() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.seq += 1
+
+	return p.seq
+}
+
+// adaptMessages records the messages of a request, reading their parts.
+func adaptMessages(msgs []llmberjack.Message) ([]Message, error) {
+	messages := make([]Message, 0, len(msgs))
+
+	for _, msg := range msgs {
+		m := Message{
+			Role:     msg.Role,
+			Type:     msg.Type,
+			Parts:    make([]string, 0, len(msg.Parts)),
+			MimeType: msg.MimeType,
+			Url:      msg.Url,
+			Filename: msg.Filename,
+			Tool:     msg.Tool,
+		}
+
+		for _, part := range msg.Parts {
+			buf, err := io.ReadAll(part)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not read message part")
+			}
+
+			// Rewind the part when possible, so the request can still be
+			// inspected from the test.
+			if seeker, ok := part.(io.Seeker); ok {
+				_, _ = seeker.Seek(0, io.SeekStart)
+			}
+
+			m.Parts = append(m.Parts, string(buf))
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+func outputMessages(candidate llmberjack.ResponseCandidate) []Message {
+	if len(candidate.ToolCalls) == 0 {
+		return []Message{{Role: llmberjack.RoleAi, Type: llmberjack.TypeText, Parts: []string{candidate.Text}}}
+	}
+
+	return lo.Map(candidate.ToolCalls, func(call llmberjack.ResponseToolCall, _ int) Message {
+		return Message{Role: llmberjack.RoleAi, Type: llmberjack.TypeText, Tool: &call}
+	})
+}
@@ -0,0 +1,312 @@
+package llmberjacktest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/checkmarble/llmberjack/llmberjacktest"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type Output struct {
+	Reply string `json:"reply"`
+}
+
+type WeatherParams struct {
+	City string `json:"city"`
+}
+
+func TestProvider(t *testing.T) {
+	p1 := llmberjacktest.NewProvider()
+	p2 := llmberjacktest.NewProvider()
+
+	llm, _ := llmberjack.New(
+		llmberjack.WithProvider("p1", p1),
+		llmberjack.WithProvider("p2", p2),
+		llmberjack.WithDefaultModel("themodel"),
+	)
+
+	p1.Enqueue(llmberjacktest.JSON(Output{Reply: "Hello"}).WithUsage(llmberjack.Usage{TotalTokens: 10}))
+	p2.Enqueue(llmberjacktest.Text("Hello from p2"))
+
+	resp, err := llmberjack.NewRequest[Output]().
+		WithText(llmberjack.RoleUser, "Hello").
+		WithTemperature(0.5).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10, resp.Usage.TotalTokens)
+
+	output, err := resp.Get(0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", output.Reply)
+
+	resp2, err := llmberjack.NewUntypedRequest().WithProvider("p2").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello from p2", resp2.Candidates[0].Text)
+
+	call, ok := p1.LastCall()
+
+	assert.True(t, ok)
+	assert.Equal(t, "themodel", call.Model)
+	assert.Len(t, call.Messages, 1)
+	assert.Equal(t, llmberjack.RoleUser, call.Messages[0].Role)
+	assert.Equal(t, "Hello", call.Messages[0].Text())
+	assert.Equal(t, 0.5, *call.Requester.ToRequest().Temperature)
+	assert.Len(t, p2.Calls(), 1)
+
+	_, err = llmberjack.NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, llmberjacktest.ErrNoReply)
+}
+
+func TestProviders(t *testing.T) {
+	fakes := llmberjacktest.NewProviders()
+
+	llm, _ := llmberjack.New(
+		llmberjack.WithProvider("vertex", fakes.Provider("vertex")),
+		llmberjack.WithProvider("openai", fakes.Provider("openai")),
+		llmberjack.WithFallbackProviders("openai"),
+	)
+
+	fakes.
+		Enqueue("vertex", llmberjacktest.Error(&llmberjack.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})).
+		Enqueue("openai", llmberjacktest.Text("Hello from openai"), llmberjacktest.Text("Hello again"))
+
+	resp, err := llmberjack.NewUntypedRequest().WithText(llmberjack.RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello from openai", resp.Candidates[0].Text)
+	assert.Equal(t, 1, fakes.Pending())
+
+	calls := fakes.Calls()
+
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "vertex", calls[0].Provider)
+	assert.Equal(t, "openai", calls[1].Provider)
+	assert.Same(t, fakes.Provider("openai"), fakes.Provider("openai"))
+	assert.Len(t, fakes.Provider("openai").Calls(), 1)
+}
+
+func TestProviderToolCalls(t *testing.T) {
+	p := llmberjacktest.NewProvider()
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(p))
+
+	p.Enqueue(
+		llmberjacktest.ToolCalls(llmberjacktest.ToolCall("get_weather", WeatherParams{City: "Paris"})),
+		llmberjacktest.Text("It is sunny in Paris."),
+	)
+
+	tool := llmberjack.NewTool[WeatherParams]("get_weather", "Get the weather", llmberjack.Function(func(args WeatherParams) (string, error) {
+		return "Sunny in " + args.City, nil
+	}))
+
+	resp, err := llmberjack.NewUntypedRequest().
+		CreateThread().
+		WithText(llmberjack.RoleUser, "What is the weather in Paris?").
+		WithTools(tool).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Len(t, resp.Candidates[0].ToolCalls, 1)
+	assert.NotEmpty(t, resp.Candidates[0].ToolCalls[0].Id)
+
+	resp, err = llmberjack.NewUntypedRequest().
+		FromCandidate(resp, 0).
+		WithToolExecution(tool).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "It is sunny in Paris.", resp.Candidates[0].Text)
+
+	call, _ := p.LastCall()
+
+	assert.Len(t, call.Messages, 1)
+	assert.Equal(t, llmberjack.RoleTool, call.Messages[0].Role)
+	assert.Equal(t, "Sunny in Paris", call.Messages[0].Text())
+	assert.Equal(t, "get_weather", call.Messages[0].Tool.Name)
+
+	resp.Candidates[0].SelectCandidate()
+
+	history := p.History(resp.ThreadId)
+
+	assert.Len(t, history, 4)
+	assert.Equal(t, "What is the weather in Paris?", history[0].Text())
+	assert.Equal(t, "get_weather", history[1].Tool.Name)
+	assert.Equal(t, "Sunny in Paris", history[2].Text())
+	assert.Equal(t, "It is sunny in Paris.", history[3].Text())
+}
+
+func TestProviderErrorsAndLatency(t *testing.T) {
+	p := llmberjacktest.NewProvider()
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(p),
+		llmberjack.WithRetryPolicy(llmberjack.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+	)
+
+	p.Enqueue(
+		llmberjacktest.Error(&llmberjack.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}),
+		llmberjacktest.Text("Hello"),
+		llmberjacktest.Text("Too late").WithLatency(time.Hour),
+	)
+
+	resp, err := llmberjack.NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	assert.Len(t, p.Calls(), 2)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = llmberjack.NewUntypedRequest().Do(ctx, llm)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, p.Pending())
+}
+
+func TestProviderHistoryPolicy(t *testing.T) {
+	p := llmberjacktest.NewProvider()
+	summarizer := llmberjacktest.NewProvider()
+
+	llm, _ := llmberjack.New(
+		llmberjack.WithProvider("main", p),
+		llmberjack.WithProvider("summarizer", summarizer),
+	)
+
+	p.Enqueue(
+		llmberjacktest.Text("First reply"),
+		llmberjacktest.Text("Second reply"),
+		llmberjacktest.Text("Third reply"),
+		llmberjacktest.Text("Fourth reply"),
+	)
+	summarizer.Enqueue(
+		llmberjacktest.Text("The user said hello."),
+		llmberjacktest.Text("The user said hello and asked how the assistant was."),
+	)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		CreateThread().
+		WithInstruction("Be concise.").
+		WithText(llmberjack.RoleUser, "Hello").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	resp.ThreadId.SetHistoryPolicy(llmberjack.HistoryPolicy{
+		MaxTurns:   1,
+		Summarizer: &llmberjack.HistorySummarizer{Provider: "summarizer", Model: "cheap"},
+	})
+
+	for _, text := range []string{"How are you?", "Goodbye"} {
+		resp, err = llmberjack.NewUntypedRequest().
+			FromCandidate(resp, 0).
+			WithText(llmberjack.RoleUser, text).
+			Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+	}
+
+	call, _ := summarizer.LastCall()
+
+	assert.Equal(t, "cheap", call.Model)
+	assert.Contains(t, call.Messages[1].Text(), "user: Hello")
+	assert.Contains(t, call.Messages[1].Text(), "assistant: First reply")
+
+	history := p.History(resp.ThreadId)
+
+	assert.Len(t, history, 5)
+	assert.Equal(t, "Be concise.", history[0].Text())
+	assert.Equal(t, llmberjack.RoleUser, history[1].Role)
+	assert.Contains(t, history[1].Text(), "The user said hello.")
+	assert.Equal(t, "How are you?", history[2].Text())
+	assert.Equal(t, "Second reply", history[3].Text())
+	assert.Equal(t, "Goodbye", history[4].Text())
+
+	// The summary is summarized again with the next removed turn.
+	resp, err = llmberjack.NewUntypedRequest().
+		FromCandidate(resp, 0).
+		WithText(llmberjack.RoleUser, "Bye").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	call, _ = summarizer.LastCall()
+
+	assert.Contains(t, call.Messages[1].Text(), "The user said hello.")
+	assert.Contains(t, call.Messages[1].Text(), "assistant: Second reply")
+
+	history = p.History(resp.ThreadId)
+
+	assert.Len(t, history, 5)
+	assert.Contains(t, history[1].Text(), "asked how the assistant was")
+	assert.Equal(t, "Goodbye", history[2].Text())
+	assert.Equal(t, 0, summarizer.Pending())
+}
+
+func TestProviderCacheInThread(t *testing.T) {
+	p := llmberjacktest.NewProvider()
+
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(p),
+		llmberjack.WithCache(llmberjack.NewMemoryCache(10), time.Hour),
+	)
+
+	p.Enqueue(
+		llmberjacktest.Text("Hi"),
+		llmberjacktest.Text("Fine"),
+		llmberjacktest.Text("Hi again"),
+		llmberjacktest.Text("Not cached"),
+	)
+
+	converse := func(cached bool, texts ...string) *llmberjack.ThreadId {
+		var resp *llmberjack.Response[string]
+
+		for idx, text := range texts {
+			req := llmberjack.NewUntypedRequest().CacheInThread().WithText(llmberjack.RoleUser, text)
+
+			if idx == 0 {
+				req = req.CreateThread()
+			} else {
+				req = req.FromCandidate(resp, 0)
+			}
+
+			var err error
+
+			resp, err = req.Do(t.Context(), llm)
+
+			assert.Nil(t, err)
+			assert.Equal(t, cached, resp.Cached)
+		}
+
+		resp.Candidates[0].SelectCandidate()
+
+		return resp.ThreadId
+	}
+
+	first := converse(false, "Hello", "How are you?")
+	second := converse(true, "Hello", "How are you?")
+
+	// Cached responses are added to the history of the thread.
+	assert.Len(t, p.Calls(), 2)
+	assert.Equal(t, p.History(first), p.History(second))
+	assert.Len(t, p.History(second), 4)
+
+	// The history of the thread is part of the key.
+	resp, _ := llmberjack.NewUntypedRequest().InThread(second).WithText(llmberjack.RoleUser, "Hello").CacheInThread().Do(t.Context(), llm)
+
+	assert.False(t, resp.Cached)
+	assert.Equal(t, "Hi again", resp.Candidates[0].Text)
+
+	// Requests bound to a thread are only cached when opting in.
+	resp, _ = llmberjack.NewUntypedRequest().CreateThread().WithText(llmberjack.RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.False(t, resp.Cached)
+	assert.Equal(t, "Not cached", resp.Candidates[0].Text)
+}
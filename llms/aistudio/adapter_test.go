@@ -38,7 +38,7 @@ func TestRequestAdapter(t *testing.T) {
 		req := llmberjack.NewUntypedRequest().
 			WithModel("themodel")
 
-		contents, cfg, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		contents, cfg, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.Nil(t, cfg.SystemInstruction)
@@ -51,7 +51,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithInstruction("system prompt", "system prompt 2").
 			WithInstructionReader(strings.NewReader("system prompt 3"))
 
-		contents, cfg, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		contents, cfg, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.NotNil(t, cfg.SystemInstruction)
@@ -67,7 +67,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithText(llmberjack.RoleUser, "user prompt", "user prompt 2").
 			WithTextReader(llmberjack.RoleUser, strings.NewReader("user prompt 3"))
 
-		contents, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		contents, _, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.Len(t, contents, 2)
@@ -78,6 +78,64 @@ func TestRequestAdapter(t *testing.T) {
 		assert.Equal(t, "user prompt 3", contents[1].Parts[0].Text)
 	})
 
+	t.Run("with images", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithImage(llmberjack.RoleUser, strings.NewReader("image"), "image/png").
+			WithImageUrl(llmberjack.RoleUser, "https://example.com/image.jpg")
+
+		contents, _, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+
+		assert.Nil(t, err)
+		assert.Len(t, contents, 2)
+		assert.Equal(t, "image/png", contents[0].Parts[0].InlineData.MIMEType)
+		assert.Equal(t, []byte("image"), contents[0].Parts[0].InlineData.Data)
+		assert.Equal(t, "image/jpeg", contents[1].Parts[0].FileData.MIMEType)
+		assert.Equal(t, "https://example.com/image.jpg", contents[1].Parts[0].FileData.FileURI)
+
+		_, _, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithImageUrl(llmberjack.RoleUser, "https://example.com/image"), lo.FromPtr[RequestOptions](nil))
+
+		assert.ErrorContains(t, err, "could not determine the MIME type")
+	})
+
+	t.Run("with documents", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithFile(llmberjack.RoleUser, strings.NewReader("document"), "application/pdf", "document.pdf").
+			WithFile(llmberjack.RoleUser, strings.NewReader("a,b"), "text/csv; charset=utf-8", "document.csv")
+
+		contents, _, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+
+		assert.Nil(t, err)
+		assert.Len(t, contents, 2)
+		assert.Equal(t, "application/pdf", contents[0].Parts[0].InlineData.MIMEType)
+		assert.Equal(t, []byte("document"), contents[0].Parts[0].InlineData.Data)
+		assert.Equal(t, "text/csv; charset=utf-8", contents[1].Parts[0].InlineData.MIMEType)
+
+		_, _, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithFile(llmberjack.RoleUser, strings.NewReader("document"), "application/msword", "document.doc"), lo.FromPtr[RequestOptions](nil))
+
+		assert.ErrorIs(t, err, llmberjack.ErrUnsupportedContent)
+		assert.ErrorContains(t, err, "does not accept documents of type 'application/msword'")
+	})
+
+	t.Run("with audio", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithAudio(llmberjack.RoleUser, strings.NewReader("audio"), "audio/ogg")
+
+		contents, _, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+
+		assert.Nil(t, err)
+		assert.Len(t, contents, 1)
+		assert.Equal(t, "audio/ogg", contents[0].Parts[0].InlineData.MIMEType)
+		assert.Equal(t, []byte("audio"), contents[0].Parts[0].InlineData.Data)
+
+		_, _, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithAudio(llmberjack.RoleUser, strings.NewReader("audio"), "audio/webm"), lo.FromPtr[RequestOptions](nil))
+
+		assert.ErrorIs(t, err, llmberjack.ErrUnsupportedContent)
+		assert.ErrorContains(t, err, "does not accept audio of type 'audio/webm'")
+	})
+
 	t.Run("with tools", func(t *testing.T) {
 		type Args1 struct {
 			Number int `json:"number" jsonschema_description:"Number description"`
@@ -96,7 +154,7 @@ func TestRequestAdapter(t *testing.T) {
 				})),
 			)
 
-		_, cfg, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		_, cfg, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.Len(t, cfg.Tools, 2)
@@ -140,7 +198,7 @@ func TestRequestAdapter(t *testing.T) {
 
 		req := llmberjack.NewRequest[Format]()
 
-		_, cfg, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		_, cfg, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.NotNil(t, cfg.ResponseJsonSchema)
@@ -164,7 +222,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithTemperature(0.1).
 			WithTopP(0.1)
 
-		_, cfg, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
+		_, cfg, _, err := p.adaptRequest(llm, req, lo.FromPtr[RequestOptions](nil))
 
 		assert.Nil(t, err)
 		assert.EqualValues(t, 10, cfg.CandidateCount)
@@ -180,7 +238,7 @@ func TestRequestAdapter(t *testing.T) {
 			TopK:         lo.ToPtr(0.2),
 		}
 
-		_, cfg, err := p.adaptRequest(llm, req, opts)
+		_, cfg, _, err := p.adaptRequest(llm, req, opts)
 
 		assert.Nil(t, err)
 
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"math"
+	"mime"
 	"reflect"
+	"strings"
 
 	llmberjack "github.com/checkmarble/llmberjack"
 	"github.com/checkmarble/llmberjack/internal"
@@ -13,6 +16,14 @@ import (
 	"google.golang.org/genai"
 )
 
+// maxStopSequences is the maximum number of stop sequences accepted by the
+// Gemini API.
+const maxStopSequences = 5
+
+// maxTopLogprobs is the maximum number of alternatives to each token the Gemini
+// API can return.
+const maxTopLogprobs = 20
+
 type AiStudio struct {
 	client  *genai.Client
 	history llmberjack.History[*genai.Content]
@@ -28,6 +39,18 @@ func (*AiStudio) RequestOptionsType() reflect.Type {
 	return reflect.TypeFor[RequestOptions]()
 }
 
+func (p *AiStudio) DefaultModel() string {
+	return lo.FromPtr(p.model)
+}
+
+func (p *AiStudio) SystemName() string {
+	if p.backend == genai.BackendVertexAI {
+		return "gcp.vertex_ai"
+	}
+
+	return "gcp.gemini"
+}
+
 func New(opts ...Opt) (*AiStudio, error) {
 	llm := AiStudio{
 		backend: genai.BackendGeminiAPI,
@@ -87,33 +110,296 @@ func (p *AiStudio) ChatCompletion(ctx context.Context, llm internal.Adapter, req
 
 	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
 
-	contents, cfg, err := p.adaptRequest(llm, requester, opts)
+	contents, cfg, inputs, err := p.adaptRequest(llm, requester, opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not adapt request")
 	}
 
 	response, err := p.client.Models.GenerateContent(ctx, *model, contents, cfg)
 	if err != nil {
-		return nil, errors.Wrap(err, "LLM provider failed to generate content")
+		return nil, errors.Wrap(wrapError(err), "LLM provider failed to generate content")
+	}
+
+	resp, err := p.adaptResponse(llm, response, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	p.saveInputs(requester, inputs)
+
+	return resp, nil
+}
+
+func (p *AiStudio) ChatCompletionStream(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester, onDelta func(llmberjack.StreamDelta) bool) (*llmberjack.InnerResponse, error) {
+	model, ok := lo.Coalesce(requester.ToRequest().Model, p.model, lo.ToPtr(llm.DefaultModel()))
+	if !ok {
+		return nil, errors.New("no model was configured")
+	}
+
+	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
+
+	contents, cfg, inputs, err := p.adaptRequest(llm, requester, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not adapt request")
+	}
+
+	var response *genai.GenerateContentResponse
+
+	for chunk, err := range p.client.Models.GenerateContentStream(ctx, *model, contents, cfg) {
+		if err != nil {
+			return nil, errors.Wrap(wrapError(err), "LLM provider failed to generate content")
+		}
+
+		response = accumulateChunk(response, chunk)
+
+		for idx, candidate := range chunk.Candidates {
+			if candidate.Content == nil {
+				continue
+			}
+
+			delta := llmberjack.StreamDelta{Candidate: idx}
+
+			for _, part := range candidate.Content.Parts {
+				switch {
+				case part.FunctionCall != nil:
+					params, err := json.Marshal(part.FunctionCall.Args)
+					if err != nil {
+						return nil, errors.Wrap(err, "failed to parse tool call parameters")
+					}
+
+					delta.ToolCalls = append(delta.ToolCalls, llmberjack.StreamToolCallDelta{
+						Index:      len(delta.ToolCalls),
+						Id:         part.FunctionCall.ID,
+						Name:       part.FunctionCall.Name,
+						Parameters: string(params),
+					})
+				case part.Thought:
+					delta.Thoughts += part.Text
+				default:
+					delta.Text += part.Text
+				}
+			}
+
+			if delta.Text == "" && delta.Thoughts == "" && len(delta.ToolCalls) == 0 {
+				continue
+			}
+
+			if !onDelta(delta) {
+				return nil, llmberjack.ErrStreamStopped
+			}
+		}
+	}
+
+	if response == nil {
+		return nil, errors.New("LLM provider generated no content")
+	}
+
+	resp, err := p.adaptResponse(llm, response, requester)
+	if err != nil {
+		return nil, err
 	}
 
-	return p.adaptResponse(llm, response, requester)
+	p.saveInputs(requester, inputs)
+
+	return resp, nil
 }
 
-func (p *AiStudio) adaptRequest(_ internal.Adapter, requester llmberjack.Requester, opts RequestOptions) ([]*genai.Content, *genai.GenerateContentConfig, error) {
+// accumulateChunk merges a streamed response chunk into the aggregated
+// response. Consecutive text parts of the same kind are concatenated so the
+// result has the same shape as a non-streamed response.
+func accumulateChunk(acc, chunk *genai.GenerateContentResponse) *genai.GenerateContentResponse {
+	if acc == nil {
+		acc = &genai.GenerateContentResponse{}
+	}
+
+	acc.ResponseID = lo.CoalesceOrEmpty(chunk.ResponseID, acc.ResponseID)
+	acc.ModelVersion = lo.CoalesceOrEmpty(chunk.ModelVersion, acc.ModelVersion)
+
+	if !chunk.CreateTime.IsZero() {
+		acc.CreateTime = chunk.CreateTime
+	}
+	if chunk.UsageMetadata != nil {
+		acc.UsageMetadata = chunk.UsageMetadata
+	}
+
+	for idx, candidate := range chunk.Candidates {
+		for len(acc.Candidates) <= idx {
+			acc.Candidates = append(acc.Candidates, &genai.Candidate{
+				Content: &genai.Content{Role: genai.RoleModel},
+			})
+		}
+
+		target := acc.Candidates[idx]
+
+		if candidate.FinishReason != "" {
+			target.FinishReason = candidate.FinishReason
+		}
+		if candidate.GroundingMetadata != nil {
+			target.GroundingMetadata = candidate.GroundingMetadata
+		}
+		if candidate.LogprobsResult != nil {
+			if target.LogprobsResult == nil {
+				target.LogprobsResult = &genai.LogprobsResult{}
+			}
+
+			target.LogprobsResult.ChosenCandidates = append(target.LogprobsResult.ChosenCandidates, candidate.LogprobsResult.ChosenCandidates...)
+			target.LogprobsResult.TopCandidates = append(target.LogprobsResult.TopCandidates, candidate.LogprobsResult.TopCandidates...)
+		}
+		if candidate.Content == nil {
+			continue
+		}
+
+		for _, part := range candidate.Content.Parts {
+			if n := len(target.Content.Parts); n > 0 && isTextPart(part) && isTextPart(target.Content.Parts[n-1]) && target.Content.Parts[n-1].Thought == part.Thought {
+				target.Content.Parts[n-1].Text += part.Text
+				continue
+			}
+
+			p := *part
+			target.Content.Parts = append(target.Content.Parts, &p)
+		}
+	}
+
+	return acc
+}
+
+func isTextPart(part *genai.Part) bool {
+	return part.FunctionCall == nil && part.FunctionResponse == nil && part.InlineData == nil && part.FileData == nil &&
+		part.ExecutableCode == nil && part.CodeExecutionResult == nil
+}
+
+// Render returns the payload that would be sent to the Gemini API for the
+// request: the model, the contents and the generation configuration.
+func (p *AiStudio) Render(llm internal.Adapter, requester llmberjack.Requester) (json.RawMessage, error) {
+	model, ok := lo.Coalesce(requester.ToRequest().Model, p.model, lo.ToPtr(llm.DefaultModel()))
+	if !ok {
+		return nil, errors.New("no model was configured")
+	}
+
+	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
+
+	contents, cfg, _, err := p.adaptRequest(llm, requester, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not adapt request")
+	}
+
+	return json.Marshal(struct {
+		Model    string                       `json:"model"`
+		Contents []*genai.Content             `json:"contents"`
+		Config   *genai.GenerateContentConfig `json:"config"`
+	}{*model, contents, cfg})
+}
+
+// CountTokens counts the input tokens of the request with the Gemini API.
+//
+// The Gemini Developer API only counts contents, so system instructions and
+// tool declarations are counted as an additional text content. The response
+// schema is always counted that way.
+func (p *AiStudio) CountTokens(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester) (llmberjack.TokenCount, error) {
+	model, ok := lo.Coalesce(requester.ToRequest().Model, p.model, lo.ToPtr(llm.DefaultModel()))
+	if !ok {
+		return llmberjack.TokenCount{}, errors.New("no model was configured")
+	}
+
+	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
+
+	contents, cfg, _, err := p.adaptRequest(llm, requester, opts)
+	if err != nil {
+		return llmberjack.TokenCount{}, errors.Wrap(err, "could not adapt request")
+	}
+
+	countCfg := genai.CountTokensConfig{}
+	extra := make([]*genai.Part, 0)
+
+	switch p.backend {
+	case genai.BackendVertexAI:
+		countCfg.SystemInstruction = cfg.SystemInstruction
+		countCfg.Tools = cfg.Tools
+
+	default:
+		if cfg.SystemInstruction != nil {
+			extra = append(extra, cfg.SystemInstruction.Parts...)
+		}
+
+		for _, tool := range cfg.Tools {
+			if len(tool.FunctionDeclarations) == 0 {
+				continue
+			}
+
+			buf, err := json.Marshal(tool.FunctionDeclarations)
+			if err != nil {
+				return llmberjack.TokenCount{}, errors.Wrap(err, "could not serialize tool declarations")
+			}
+
+			extra = append(extra, genai.NewPartFromText(string(buf)))
+		}
+	}
+
+	if cfg.ResponseJsonSchema != nil {
+		buf, err := json.Marshal(cfg.ResponseJsonSchema)
+		if err != nil {
+			return llmberjack.TokenCount{}, errors.Wrap(err, "could not serialize response schema")
+		}
+
+		extra = append(extra, genai.NewPartFromText(string(buf)))
+	}
+
+	if len(extra) > 0 {
+		contents = append([]*genai.Content{{Role: genai.RoleUser, Parts: extra}}, contents...)
+	}
+
+	response, err := p.client.Models.CountTokens(ctx, *model, contents, &countCfg)
+	if err != nil {
+		return llmberjack.TokenCount{}, errors.Wrap(wrapError(err), "LLM provider failed to count tokens")
+	}
+
+	return llmberjack.TokenCount{Tokens: int(response.TotalTokens)}, nil
+}
+
+// saveInputs adds the messages sent in a request to its thread history. It is
+// only called once the provider successfully responded, so failed attempts do
+// not pollute the history.
+func (p *AiStudio) saveInputs(requester llmberjack.Requester, inputs []*genai.Content) {
+	r := requester.ToRequest()
+
+	if r.ThreadId != nil && !r.SkipSaveInput {
+		p.history.Save(r.ThreadId, inputs...)
+	}
+}
+
+// adaptRequest converts a request into the provider's format. It also returns
+// the contents from the request that should be added to the thread history.
+func (p *AiStudio) adaptRequest(_ internal.Adapter, requester llmberjack.Requester, opts RequestOptions) ([]*genai.Content, *genai.GenerateContentConfig, []*genai.Content, error) {
 	r := requester.ToRequest()
 	contents := make([]*genai.Content, 0, len(r.Messages))
+	inputs := make([]*genai.Content, 0, len(r.Messages))
 
 	if r.ThreadId != nil {
 		contents = append(contents, p.history.Load(r.ThreadId)...)
 	}
 
+	if len(r.StopSequences) > maxStopSequences {
+		return nil, nil, nil, errors.Wrapf(llmberjack.ErrUnsupportedOption, "Gemini accepts at most %d stop sequences", maxStopSequences)
+	}
+	if r.Seed != nil && (*r.Seed < math.MinInt32 || *r.Seed > math.MaxInt32) {
+		return nil, nil, nil, errors.Wrap(llmberjack.ErrUnsupportedOption, "Gemini only accepts 32-bit seeds")
+	}
+	if r.Logprobs != nil && *r.Logprobs > maxTopLogprobs {
+		return nil, nil, nil, errors.Wrapf(llmberjack.ErrUnsupportedOption, "Gemini returns at most %d log probabilities per token", maxTopLogprobs)
+	}
+
 	cfg := genai.GenerateContentConfig{
-		CandidateCount:  int32(lo.FromPtr(r.MaxCandidates)),
-		MaxOutputTokens: int32(lo.FromPtr(r.MaxTokens)),
-		Temperature:     internal.MaybeF64ToF32(r.Temperature),
-		TopP:            internal.MaybeF64ToF32(r.TopP),
-		TopK:            internal.MaybeF64ToF32(opts.TopK),
+		CandidateCount:   int32(lo.FromPtr(r.MaxCandidates)),
+		MaxOutputTokens:  int32(lo.FromPtr(r.MaxTokens)),
+		Temperature:      internal.MaybeF64ToF32(r.Temperature),
+		TopP:             internal.MaybeF64ToF32(r.TopP),
+		TopK:             internal.MaybeF64ToF32(opts.TopK),
+		StopSequences:    r.StopSequences,
+		Seed:             internal.MaybeIntToInt32(r.Seed),
+		PresencePenalty:  internal.MaybeF64ToF32(r.PresencePenalty),
+		FrequencyPenalty: internal.MaybeF64ToF32(r.FrequencyPenalty),
+		ResponseLogprobs: r.Logprobs != nil,
+		Logprobs:         internal.MaybeIntToInt32(r.Logprobs),
 	}
 
 	if lo.FromPtr(opts.GoogleSearch) {
@@ -160,24 +446,9 @@ func (p *AiStudio) adaptRequest(_ internal.Adapter, requester llmberjack.Request
 
 Messages:
 	for _, msg := range r.Messages {
-		parts := make([]*genai.Part, 0, len(msg.Parts))
-
-		for _, part := range msg.Parts {
-			if seeker, ok := part.(io.ReadSeeker); ok {
-				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
-					return nil, nil, err
-				}
-			}
-
-			buf, err := io.ReadAll(part)
-			if err != nil {
-				return nil, nil, errors.Wrap(err, "could not read content part")
-			}
-
-			switch msg.Type {
-			case llmberjack.TypeText:
-				parts = append(parts, genai.NewPartFromText(string(buf)))
-			}
+		parts, err := adaptParts(msg)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
 		role := genai.RoleUser
@@ -189,7 +460,7 @@ Messages:
 			role = genai.RoleUser
 		case llmberjack.RoleTool:
 			if msg.Tool == nil {
-				return nil, nil, errors.New("sent a tool response when no tool was invoked")
+				return nil, nil, nil, errors.New("sent a tool response when no tool was invoked")
 			}
 
 			msg := &genai.Content{
@@ -206,10 +477,7 @@ Messages:
 			}
 
 			contents = append(contents, msg)
-
-			if r.ThreadId != nil && !r.SkipSaveInput {
-				p.history.Save(r.ThreadId, msg)
-			}
+			inputs = append(inputs, msg)
 
 			continue Messages
 		case llmberjack.RoleSystem:
@@ -220,10 +488,7 @@ Messages:
 			}
 
 			cfg.SystemInstruction.Parts = append(cfg.SystemInstruction.Parts, parts...)
-
-			if r.ThreadId != nil && !r.SkipSaveInput {
-				p.history.Save(r.ThreadId, cfg.SystemInstruction)
-			}
+			inputs = append(inputs, cfg.SystemInstruction)
 
 			continue Messages
 		}
@@ -233,17 +498,85 @@ Messages:
 			Parts: parts,
 		}
 
-		if r.ThreadId != nil && !r.SkipSaveInput {
-			p.history.Save(r.ThreadId, content)
+		contents = append(contents, content)
+		inputs = append(inputs, content)
+	}
+
+	return contents, &cfg, inputs, nil
+}
+
+// adaptParts converts the content of a message into Gemini parts.
+func adaptParts(msg llmberjack.Message) ([]*genai.Part, error) {
+	if msg.Url != "" {
+		if msg.MimeType == "" {
+			return nil, errors.Newf("could not determine the MIME type of '%s'", msg.Url)
 		}
 
-		contents = append(contents, content)
+		return []*genai.Part{genai.NewPartFromURI(msg.Url, msg.MimeType)}, nil
+	}
+
+	if msg.Type == llmberjack.TypeDocument && !isSupportedDocument(msg.MimeType) {
+		return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "Gemini does not accept documents of type '%s'", msg.MimeType)
+	}
+
+	if msg.Type == llmberjack.TypeAudio && !lo.Contains(audioTypes, msg.MimeType) {
+		return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "Gemini does not accept audio of type '%s'", msg.MimeType)
+	}
+
+	parts := make([]*genai.Part, 0, len(msg.Parts))
+
+	for _, part := range msg.Parts {
+		if seeker, ok := part.(io.ReadSeeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+
+		buf, err := io.ReadAll(part)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read content part")
+		}
+
+		switch msg.Type {
+		case llmberjack.TypeText:
+			parts = append(parts, genai.NewPartFromText(string(buf)))
+		case llmberjack.TypeImage, llmberjack.TypeDocument, llmberjack.TypeAudio:
+			parts = append(parts, genai.NewPartFromBytes(buf, msg.MimeType))
+		default:
+			return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "Gemini does not accept messages of type %d", msg.Type)
+		}
+	}
+
+	return parts, nil
+}
+
+// audioTypes are the MIME types of the audio formats accepted by Gemini.
+var audioTypes = []string{
+	"audio/wav",
+	"audio/mp3",
+	"audio/mpeg",
+	"audio/aiff",
+	"audio/aac",
+	"audio/ogg",
+	"audio/flac",
+}
+
+// isSupportedDocument checks whether Gemini can process documents of the
+// provided MIME type as inline data: PDF files, and plain text formats.
+func isSupportedDocument(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
 	}
 
-	return contents, &cfg, nil
+	return mediaType == "application/pdf" || strings.HasPrefix(mediaType, "text/")
 }
 
 func (p *AiStudio) adaptResponse(_ internal.Adapter, response *genai.GenerateContentResponse, requester llmberjack.Requester) (*llmberjack.InnerResponse, error) {
+	if err := blockedError(response); err != nil {
+		return nil, err
+	}
+
 	resp := llmberjack.InnerResponse{
 		Id:         response.ResponseID,
 		Model:      response.ModelVersion,
@@ -251,6 +584,16 @@ func (p *AiStudio) adaptResponse(_ internal.Adapter, response *genai.GenerateCon
 		Created:    response.CreateTime,
 	}
 
+	if response.UsageMetadata != nil {
+		resp.Usage = llmberjack.Usage{
+			PromptTokens:     int(response.UsageMetadata.PromptTokenCount),
+			CompletionTokens: int(response.UsageMetadata.CandidatesTokenCount + response.UsageMetadata.ThoughtsTokenCount),
+			CachedTokens:     int(response.UsageMetadata.CachedContentTokenCount),
+			ReasoningTokens:  int(response.UsageMetadata.ThoughtsTokenCount),
+			TotalTokens:      int(response.UsageMetadata.TotalTokenCount),
+		}
+	}
+
 	for idx, candidate := range response.Candidates {
 		if len(candidate.Content.Parts) == 0 {
 			return nil, errors.New("LLM provider generated no content")
@@ -301,11 +644,24 @@ func (p *AiStudio) adaptResponse(_ internal.Adapter, response *genai.GenerateCon
 			}
 		}
 
+		var text, thoughts strings.Builder
+
+		for _, part := range candidate.Content.Parts {
+			switch part.Thought {
+			case true:
+				thoughts.WriteString(part.Text)
+			default:
+				text.WriteString(part.Text)
+			}
+		}
+
 		resp.Candidates[idx] = llmberjack.ResponseCandidate{
-			Text:         candidate.Content.Parts[0].Text,
+			Text:         text.String(),
+			Thoughts:     thoughts.String(),
 			ToolCalls:    toolCalls,
 			FinishReason: finishReason,
 			Grounding:    grounding,
+			Logprobs:     adaptLogprobs(candidate.LogprobsResult),
 			SelectCandidate: func() {
 				req := requester.ToRequest()
 
@@ -318,3 +674,28 @@ func (p *AiStudio) adaptResponse(_ internal.Adapter, response *genai.GenerateCon
 
 	return &resp, nil
 }
+
+// adaptLogprobs converts the log probabilities of the tokens of a candidate.
+func adaptLogprobs(logprobs *genai.LogprobsResult) []llmberjack.TokenLogprob {
+	if logprobs == nil || len(logprobs.ChosenCandidates) == 0 {
+		return nil
+	}
+
+	return lo.Map(logprobs.ChosenCandidates, func(c *genai.LogprobsResultCandidate, idx int) llmberjack.TokenLogprob {
+		token := llmberjack.TokenLogprob{
+			Token:   c.Token,
+			Logprob: float64(c.LogProbability),
+		}
+
+		if idx < len(logprobs.TopCandidates) && logprobs.TopCandidates[idx] != nil {
+			token.Alternatives = lo.Map(logprobs.TopCandidates[idx].Candidates, func(a *genai.LogprobsResultCandidate, _ int) llmberjack.TokenAlternative {
+				return llmberjack.TokenAlternative{
+					Token:   a.Token,
+					Logprob: float64(a.LogProbability),
+				}
+			})
+		}
+
+		return token
+	})
+}
@@ -2,6 +2,7 @@ package aistudio_test
 
 import (
 	"io"
+	"math"
 	"net/http"
 	"strings"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	llmberjack "github.com/checkmarble/llmberjack"
 	"github.com/checkmarble/llmberjack/llms/aistudio"
+	"github.com/cockroachdb/errors"
 	"github.com/h2non/gock"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
@@ -30,9 +32,24 @@ const aistudioResponse = `{
 			}
 		}
 	],
-	"createTime": "2025-07-13T16:20:00Z"
+	"createTime": "2025-07-13T16:20:00Z",
+	"usageMetadata": {
+		"promptTokenCount": 100,
+		"candidatesTokenCount": 40,
+		"cachedContentTokenCount": 20,
+		"thoughtsTokenCount": 10,
+		"totalTokenCount": 150
+	}
 }`
 
+func TestGoogleAiSystemName(t *testing.T) {
+	gemini, _ := aistudio.New()
+	vertex, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI))
+
+	assert.Equal(t, "gcp.gemini", gemini.SystemName())
+	assert.Equal(t, "gcp.vertex_ai", vertex.SystemName())
+}
+
 func TestGoogleAiRequest(t *testing.T) {
 	defer gock.Off()
 
@@ -106,6 +123,7 @@ func TestGoogleAiRequest(t *testing.T) {
 	assert.Equal(t, "themodel", resp.Model)
 	assert.WithinDuration(t, time.Date(2025, 7, 13, 16, 20, 0, 0, time.UTC), resp.Created, 0)
 	assert.Equal(t, 1, resp.NumCandidates())
+	assert.Equal(t, llmberjack.Usage{PromptTokens: 100, CompletionTokens: 50, CachedTokens: 20, ReasoningTokens: 10, TotalTokens: 150}, resp.Usage)
 
 	candidate, err := resp.Candidate(0)
 
@@ -285,3 +303,412 @@ func TestGoogleAiRequestWithThinking(t *testing.T) {
 		})
 	}
 }
+
+func TestGoogleAiResponseParts(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(httpClient))
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(`{
+			"responseId": "theid",
+			"modelVersion": "themodel",
+			"candidates": [
+				{
+					"finishReason": "STOP",
+					"content": {
+						"role": "model",
+						"parts": [
+							{ "text": "Thinking about ", "thought": true },
+							{ "text": "the answer.", "thought": true },
+							{ "text": "Hello" },
+							{ "text": ", world!" }
+						]
+					}
+				}
+			]
+		}`)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithText(llmberjack.RoleUser, "user text").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.False(t, gock.HasUnmatchedRequest())
+
+	// All text parts are concatenated, and thoughts are split out of the text.
+	assert.Equal(t, "Hello, world!", resp.Candidates[0].Text)
+	assert.Equal(t, "Thinking about the answer.", resp.Candidates[0].Thoughts)
+}
+
+func TestGoogleAiStream(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(httpClient))
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:streamGenerateContent").
+		MatchParam("alt", "sse").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "text/event-stream").
+		BodyString(`data: {"responseId":"theid","modelVersion":"themodel","createTime":"2025-07-13T16:20:00Z","candidates":[{"content":{"role":"model","parts":[{"text":"Thinking...","thought":true}]}}]}
+
+data: {"responseId":"theid","modelVersion":"themodel","candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}
+
+data: {"responseId":"theid","modelVersion":"themodel","candidates":[{"content":{"role":"model","parts":[{"text":", world!"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3,"totalTokenCount":18}}
+
+`)
+
+	stream, response := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithText(llmberjack.RoleUser, "user text").
+		Stream(t.Context(), llm)
+
+	deltas := []llmberjack.StreamDelta{}
+
+	for delta, err := range stream {
+		assert.Nil(t, err)
+
+		deltas = append(deltas, delta)
+	}
+
+	assert.False(t, gock.HasUnmatchedRequest())
+	assert.Len(t, deltas, 3)
+	assert.Equal(t, "Thinking...", deltas[0].Thoughts)
+	assert.Equal(t, "Hello", deltas[1].Text)
+	assert.Equal(t, ", world!", deltas[2].Text)
+
+	resp, err := response()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "theid", resp.Id)
+	assert.Equal(t, "themodel", resp.Model)
+	assert.WithinDuration(t, time.Date(2025, 7, 13, 16, 20, 0, 0, time.UTC), resp.Created, 0)
+	assert.Equal(t, 1, resp.NumCandidates())
+	assert.Equal(t, "Hello, world!", resp.Candidates[0].Text)
+	assert.Equal(t, "Thinking...", resp.Candidates[0].Thoughts)
+	assert.Equal(t, llmberjack.Usage{PromptTokens: 10, CompletionTokens: 8, ReasoningTokens: 3, TotalTokens: 18}, resp.Usage)
+	assert.Equal(t, llmberjack.FinishReasonStop, resp.Candidates[0].FinishReason)
+}
+
+func TestGoogleAiRetry(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(provider),
+		llmberjack.WithHttpClient(httpClient),
+		llmberjack.WithRetryPolicy(llmberjack.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		Reply(http.StatusTooManyRequests).
+		SetHeader("content-type", "application/json").
+		BodyString(`{"error":{"code":429,"message":"Resource exhausted","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.001s"}]}}`)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(aistudioResponse)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		CreateThread().
+		WithText(llmberjack.RoleUser, "user text").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			// The input from the retried request must only be present once.
+			assert.EqualValues(t, 3, gjson.GetBytes(body, "contents.#").Int())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(aistudioResponse)
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		FromCandidate(resp, 0).
+		WithText(llmberjack.RoleUser, "other text").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGoogleAiErrorKinds(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(httpClient))
+	gock.InterceptClient(httpClient)
+
+	tests := []struct {
+		status   int
+		body     string
+		expected error
+	}{
+		{http.StatusForbidden, `{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`, llmberjack.ErrAuthentication},
+		{http.StatusTooManyRequests, `{"error":{"code":429,"message":"Resource exhausted","status":"RESOURCE_EXHAUSTED"}}`, llmberjack.ErrRateLimited},
+		{http.StatusBadRequest, `{"error":{"code":400,"message":"The input token count (2000000) exceeds the maximum number of tokens allowed (1048576).","status":"INVALID_ARGUMENT"}}`, llmberjack.ErrContextLengthExceeded},
+		{http.StatusBadRequest, `{"error":{"code":400,"message":"Invalid value","status":"INVALID_ARGUMENT"}}`, llmberjack.ErrInvalidRequest},
+		{http.StatusServiceUnavailable, `{"error":{"code":503,"message":"The model is overloaded","status":"UNAVAILABLE"}}`, llmberjack.ErrProviderUnavailable},
+		{http.StatusOK, `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"}}`, llmberjack.ErrContentFiltered},
+	}
+
+	for _, tt := range tests {
+		gock.New("https://location-aiplatform.googleapis.com").
+			Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+			Reply(tt.status).
+			SetHeader("content-type", "application/json").
+			BodyString(tt.body)
+
+		_, err := llmberjack.NewUntypedRequest().WithModel("themodel").Do(t.Context(), llm)
+
+		assert.ErrorIs(t, err, tt.expected)
+
+		var providerErr *llmberjack.ProviderError
+
+		assert.True(t, errors.As(err, &providerErr))
+		assert.Equal(t, "aistudio", providerErr.Provider)
+		assert.NotEmpty(t, providerErr.Code)
+	}
+
+	assert.True(t, gock.IsDone())
+}
+
+func TestGoogleAiRender(t *testing.T) {
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(&http.Client{}))
+
+	payload, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithInstruction("system text").
+		WithText(llmberjack.RoleUser, "user text").
+		WithMaxTokens(100).
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "themodel", gjson.GetBytes(payload, "model").String())
+	assert.EqualValues(t, 1, gjson.GetBytes(payload, "contents.#").Int())
+	assert.Equal(t, "user", gjson.GetBytes(payload, "contents.0.role").String())
+	assert.Equal(t, "user text", gjson.GetBytes(payload, "contents.0.parts.0.text").String())
+	assert.Equal(t, "system text", gjson.GetBytes(payload, "config.systemInstruction.parts.0.text").String())
+	assert.EqualValues(t, 100, gjson.GetBytes(payload, "config.maxOutputTokens").Int())
+}
+
+func TestGoogleAiCacheInThread(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(provider),
+		llmberjack.WithHttpClient(httpClient),
+		llmberjack.WithCache(llmberjack.NewMemoryCache(10), time.Hour),
+	)
+
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(aistudioResponse)
+
+	var threadId *llmberjack.ThreadId
+
+	for _, cached := range []bool{false, true} {
+		resp, err := llmberjack.NewUntypedRequest().
+			WithModel("themodel").
+			CreateThread().
+			CacheInThread().
+			WithText(llmberjack.RoleUser, "user text").
+			Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, cached, resp.Cached)
+
+		resp.Candidates[0].SelectCandidate()
+		threadId = resp.ThreadId
+	}
+
+	// The cached response was added to the history of the second thread.
+	payload, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		InThread(threadId).
+		WithText(llmberjack.RoleUser, "second text").
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, gjson.GetBytes(payload, "contents.#").Int())
+	assert.Equal(t, "user text", gjson.GetBytes(payload, "contents.0.parts.0.text").String())
+	assert.Equal(t, "model", gjson.GetBytes(payload, "contents.1.role").String())
+	assert.Equal(t, `{"reply":"The JSON response from the provider."}`, gjson.GetBytes(payload, "contents.1.parts.0.text").String())
+	assert.True(t, gock.IsDone())
+}
+
+func TestGoogleAiCountTokens(t *testing.T) {
+	defer gock.Off()
+
+	type Output struct {
+		Reply string `json:"reply"`
+	}
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(httpClient))
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:countTokens").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			assert.Equal(t, "system text", gjson.GetBytes(body, "systemInstruction.parts.0.text").String())
+
+			// The response schema is counted as an additional content.
+			assert.EqualValues(t, 2, gjson.GetBytes(body, "contents.#").Int())
+			assert.Contains(t, gjson.GetBytes(body, "contents.0.parts.0.text").String(), `"reply"`)
+			assert.Equal(t, "user text", gjson.GetBytes(body, "contents.1.parts.0.text").String())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(`{"totalTokens": 42}`)
+
+	count, err := llmberjack.NewRequest[Output]().
+		WithModel("themodel").
+		WithInstruction("system text").
+		WithText(llmberjack.RoleUser, "user text").
+		CountTokens(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, llmberjack.TokenCount{Tokens: 42}, count)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGoogleAiSampling(t *testing.T) {
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(&http.Client{}))
+
+	payload, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithStopSequences("END", "STOP").
+		WithSeed(42).
+		WithPresencePenalty(0.5).
+		WithFrequencyPenalty(-0.5).
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `["END","STOP"]`, gjson.GetBytes(payload, "config.stopSequences").Raw)
+	assert.EqualValues(t, 42, gjson.GetBytes(payload, "config.seed").Int())
+	assert.Equal(t, 0.5, gjson.GetBytes(payload, "config.presencePenalty").Float())
+	assert.Equal(t, -0.5, gjson.GetBytes(payload, "config.frequencyPenalty").Float())
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithStopSequences("1", "2", "3", "4", "5", "6").
+		Render(llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithSeed(math.MaxInt32 + 1).
+		Render(llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+}
+
+func TestGoogleAiLogprobs(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	provider, _ := aistudio.New(aistudio.WithBackend(genai.BackendVertexAI), aistudio.WithLocation("location"), aistudio.WithProject("project"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider), llmberjack.WithHttpClient(httpClient))
+	gock.InterceptClient(httpClient)
+
+	gock.New("https://location-aiplatform.googleapis.com").
+		Post("/v1beta1/projects/project/locations/location/publishers/google/models/themodel:generateContent").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			assert.True(t, gjson.GetBytes(body, "generationConfig.responseLogprobs").Bool())
+			assert.EqualValues(t, 2, gjson.GetBytes(body, "generationConfig.logprobs").Int())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(`{
+			"responseId": "theid",
+			"modelVersion": "themodel",
+			"candidates": [{
+				"finishReason": "STOP",
+				"content": { "role": "model", "parts": [{ "text": "{\"label\":\"fraud\"}" }] },
+				"logprobsResult": {
+					"chosenCandidates": [
+						{ "token": "{\"label\":\"", "logProbability": 0 },
+						{ "token": "fraud", "logProbability": -0.5 },
+						{ "token": "\"}", "logProbability": 0 }
+					],
+					"topCandidates": [
+						{ "candidates": [{ "token": "{\"label\":\"", "logProbability": 0 }] },
+						{ "candidates": [{ "token": "fraud", "logProbability": -0.5 }, { "token": "legit", "logProbability": -1 }] },
+						{ "candidates": [{ "token": "\"}", "logProbability": 0 }] }
+					]
+				}
+			}],
+			"createTime": "2025-07-13T16:20:00Z"
+		}`)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithLogprobs(2).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	candidate, _ := resp.Candidate(0)
+
+	assert.Len(t, candidate.Logprobs, 3)
+	assert.Equal(t, llmberjack.TokenLogprob{
+		Token:   "fraud",
+		Logprob: -0.5,
+		Alternatives: []llmberjack.TokenAlternative{
+			{Token: "fraud", Logprob: -0.5},
+			{Token: "legit", Logprob: -1},
+		},
+	}, candidate.Logprobs[1])
+
+	probability, err := candidate.FieldProbability("label")
+
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.607, probability, 1e-3)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,76 @@
+package aistudio
+
+import (
+	"strings"
+	"time"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/genai"
+)
+
+// wrapError converts an error returned by the SDK into a provider error.
+func wrapError(err error) error {
+	providerErr := llmberjack.ProviderError{
+		Provider: "aistudio",
+		Err:      err,
+	}
+
+	var apiErr genai.APIError
+
+	if errors.As(err, &apiErr) {
+		providerErr.StatusCode = apiErr.Code
+		providerErr.Code = apiErr.Status
+		providerErr.Message = apiErr.Message
+		providerErr.RetryAfter = retryAfter(apiErr.Details)
+
+		switch apiErr.Status {
+		case "RESOURCE_EXHAUSTED":
+			providerErr.Kind = llmberjack.ErrRateLimited
+		case "UNAUTHENTICATED", "PERMISSION_DENIED":
+			providerErr.Kind = llmberjack.ErrAuthentication
+		case "INVALID_ARGUMENT":
+			message := strings.ToLower(apiErr.Message)
+
+			if strings.Contains(message, "token count") || strings.Contains(message, "maximum number of tokens") {
+				providerErr.Kind = llmberjack.ErrContextLengthExceeded
+			}
+		}
+	}
+
+	return &providerErr
+}
+
+// blockedError returns an error if the prompt was blocked by the provider's
+// safety filters, in which case no candidate is generated.
+func blockedError(response *genai.GenerateContentResponse) error {
+	if response.PromptFeedback == nil || response.PromptFeedback.BlockReason == "" {
+		return nil
+	}
+
+	return &llmberjack.ProviderError{
+		Provider: "aistudio",
+		Code:     string(response.PromptFeedback.BlockReason),
+		Message:  response.PromptFeedback.BlockReasonMessage,
+		Kind:     llmberjack.ErrContentFiltered,
+		Err:      errors.Newf("prompt was blocked: %s", response.PromptFeedback.BlockReason),
+	}
+}
+
+// retryAfter extracts the delay the API asked to wait for before retrying from
+// the `google.rpc.RetryInfo` error detail, if present.
+func retryAfter(details []map[string]any) time.Duration {
+	for _, detail := range details {
+		if kind, _ := detail["@type"].(string); !strings.HasSuffix(kind, "google.rpc.RetryInfo") {
+			continue
+		}
+
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d
+			}
+		}
+	}
+
+	return 0
+}
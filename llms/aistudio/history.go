@@ -0,0 +1,148 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/genai"
+)
+
+const (
+	// Images are counted as a single tile.
+	tokensPerImage = 258
+	// Audio is counted at 32 tokens per second, from the bitrate of 16kHz mono
+	// WAV.
+	audioBytesPerToken = 1000
+	// Documents are counted from their size, as most of their content is
+	// extracted as text.
+	documentBytesPerToken = 4
+)
+
+// ThreadHistory describes the contents of the history of a thread.
+//
+// Tokens are estimated offline, since counting them with the API would require
+// a network call for each content.
+func (p *AiStudio) ThreadHistory(threadId *llmberjack.ThreadId) []llmberjack.HistoryMessage {
+	return p.history.Describe(threadId, describeContent)
+}
+
+// CompactThread removes the oldest contents of a thread, replacing them with a
+// user content containing the summary, if provided.
+func (p *AiStudio) CompactThread(threadId *llmberjack.ThreadId, count int, summary string) {
+	var replacement []*genai.Content
+
+	if summary != "" {
+		replacement = append(replacement, genai.NewContentFromText(summary, genai.RoleUser))
+	}
+
+	p.history.Compact(threadId, count, isInstruction, replacement...)
+}
+
+// isInstruction checks whether a content of the history is a system
+// instruction, which are saved without a role.
+func isInstruction(content *genai.Content) bool {
+	return content.Role == ""
+}
+
+func describeContent(content *genai.Content) llmberjack.HistoryMessage {
+	description := llmberjack.HistoryMessage{}
+
+	switch {
+	case isInstruction(content):
+		description.Role = llmberjack.RoleSystem
+	case content.Role == genai.RoleModel:
+		description.Role = llmberjack.RoleAi
+	default:
+		description.Role = llmberjack.RoleUser
+	}
+
+	text := make([]string, 0, len(content.Parts))
+
+	for _, part := range content.Parts {
+		switch {
+		case part.Thought:
+			continue
+		case part.FunctionCall != nil:
+			args, _ := json.Marshal(part.FunctionCall.Args)
+
+			text = append(text, fmt.Sprintf("[tool call] %s(%s)", part.FunctionCall.Name, args))
+		case part.FunctionResponse != nil:
+			output, _ := json.Marshal(part.FunctionResponse.Response)
+
+			description.Role = llmberjack.RoleTool
+			text = append(text, string(output))
+		case part.InlineData != nil:
+			switch {
+			case strings.HasPrefix(part.InlineData.MIMEType, "image/"):
+				description.Tokens += tokensPerImage
+				text = append(text, "[image]")
+			case strings.HasPrefix(part.InlineData.MIMEType, "audio/"):
+				description.Tokens += len(part.InlineData.Data) / audioBytesPerToken
+				text = append(text, "[audio]")
+			default:
+				description.Tokens += len(part.InlineData.Data) / documentBytesPerToken
+				text = append(text, "[document]")
+			}
+		case part.FileData != nil:
+			description.Tokens += tokensPerImage
+			text = append(text, fmt.Sprintf("[file %s]", part.FileData.FileURI))
+		default:
+			text = append(text, part.Text)
+		}
+	}
+
+	description.Text = strings.Join(text, "\n")
+	description.Tokens += internal.EstimateTokens(description.Text)
+
+	return description
+}
+
+// SaveInputs adds the contents of a request to its thread history without
+// sending it, for responses served from the cache.
+func (p *AiStudio) SaveInputs(llm internal.Adapter, requester llmberjack.Requester) error {
+	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
+
+	_, _, inputs, err := p.adaptRequest(llm, requester, opts)
+	if err != nil {
+		return errors.Wrap(err, "could not adapt request")
+	}
+
+	p.saveInputs(requester, inputs)
+
+	return nil
+}
+
+// SaveOutput adds a candidate to the thread history of a request, for responses
+// served from the cache. Thoughts are not kept.
+func (p *AiStudio) SaveOutput(requester llmberjack.Requester, candidate llmberjack.ResponseCandidate) {
+	req := requester.ToRequest()
+
+	if req.ThreadId == nil || req.SkipSaveOutput {
+		return
+	}
+
+	content := genai.Content{Role: genai.RoleModel}
+
+	if candidate.Text != "" {
+		content.Parts = append(content.Parts, genai.NewPartFromText(candidate.Text))
+	}
+
+	for _, call := range candidate.ToolCalls {
+		var args map[string]any
+
+		if err := json.Unmarshal(call.Parameters, &args); err != nil {
+			continue
+		}
+
+		part := genai.NewPartFromFunctionCall(call.Name, args)
+		part.FunctionCall.ID = call.Id
+
+		content.Parts = append(content.Parts, part)
+	}
+
+	p.history.Save(req.ThreadId, &content)
+}
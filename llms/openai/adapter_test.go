@@ -17,7 +17,7 @@ func TestRequestAdapter(t *testing.T) {
 		req := llmberjack.NewUntypedRequest().
 			WithModel("themodel")
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.Equal(t, "themodel", cfg.Model)
@@ -29,7 +29,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithInstruction("system prompt", "system prompt 2").
 			WithInstructionReader(strings.NewReader("system prompt 3"))
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.Len(t, cfg.Messages, 2)
@@ -44,7 +44,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithText(llmberjack.RoleUser, "user prompt", "user prompt 2").
 			WithTextReader(llmberjack.RoleUser, strings.NewReader("user prompt 3"))
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.Len(t, cfg.Messages, 2)
@@ -54,6 +54,67 @@ func TestRequestAdapter(t *testing.T) {
 		assert.Equal(t, "user prompt 3", cfg.Messages[1].OfUser.Content.OfArrayOfContentParts[0].OfText.Text)
 	})
 
+	t.Run("with images", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithImage(llmberjack.RoleUser, strings.NewReader("image"), "image/png").
+			WithImageUrl(llmberjack.RoleUser, "https://example.com/image.jpg")
+
+		cfg, _, err := p.adaptRequest(llm, req)
+
+		assert.Nil(t, err)
+		assert.Len(t, cfg.Messages, 2)
+		assert.Equal(t, "data:image/png;base64,aW1hZ2U=", cfg.Messages[0].OfUser.Content.OfArrayOfContentParts[0].OfImageURL.ImageURL.URL)
+		assert.Equal(t, "https://example.com/image.jpg", cfg.Messages[1].OfUser.Content.OfArrayOfContentParts[0].OfImageURL.ImageURL.URL)
+
+		_, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithImage(llmberjack.RoleSystem, strings.NewReader("image"), "image/png"))
+
+		assert.ErrorIs(t, err, llmberjack.ErrUnsupportedContent)
+		assert.ErrorContains(t, err, "only accepts text in non-user messages")
+	})
+
+	t.Run("with documents", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithFile(llmberjack.RoleUser, strings.NewReader("document"), "application/pdf", "document.pdf")
+
+		cfg, _, err := p.adaptRequest(llm, req)
+
+		assert.Nil(t, err)
+		assert.Len(t, cfg.Messages, 1)
+
+		file := cfg.Messages[0].OfUser.Content.OfArrayOfContentParts[0].OfFile.File
+
+		assert.Equal(t, "data:application/pdf;base64,ZG9jdW1lbnQ=", file.FileData.Value)
+		assert.Equal(t, "document.pdf", file.Filename.Value)
+
+		_, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithFile(llmberjack.RoleUser, strings.NewReader("a,b"), "text/csv", "document.csv"))
+
+		assert.ErrorIs(t, err, llmberjack.ErrUnsupportedContent)
+		assert.ErrorContains(t, err, "does not accept documents of type 'text/csv'")
+	})
+
+	t.Run("with audio", func(t *testing.T) {
+		req := llmberjack.NewUntypedRequest().
+			WithAudio(llmberjack.RoleUser, strings.NewReader("audio"), "audio/mpeg")
+
+		cfg, _, err := p.adaptRequest(llm, req)
+
+		assert.Nil(t, err)
+		assert.Len(t, cfg.Messages, 1)
+
+		audio := cfg.Messages[0].OfUser.Content.OfArrayOfContentParts[0].OfInputAudio.InputAudio
+
+		assert.Equal(t, "YXVkaW8=", audio.Data)
+		assert.Equal(t, "mp3", audio.Format)
+
+		_, _, err = p.adaptRequest(llm, llmberjack.NewUntypedRequest().
+			WithAudio(llmberjack.RoleUser, strings.NewReader("audio"), "audio/ogg"))
+
+		assert.ErrorIs(t, err, llmberjack.ErrUnsupportedContent)
+		assert.ErrorContains(t, err, "does not accept audio of type 'audio/ogg'")
+	})
+
 	t.Run("with tools", func(t *testing.T) {
 		type Args1 struct {
 			Number int `json:"number" jsonschema_description:"Number description"`
@@ -72,7 +133,7 @@ func TestRequestAdapter(t *testing.T) {
 				})),
 			)
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.Len(t, cfg.Tools, 2)
@@ -113,7 +174,7 @@ func TestRequestAdapter(t *testing.T) {
 
 		req := llmberjack.NewRequest[Format]()
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.NotNil(t, cfg.ResponseFormat.OfJSONSchema)
@@ -137,7 +198,7 @@ func TestRequestAdapter(t *testing.T) {
 			WithTemperature(0.1).
 			WithTopP(0.1)
 
-		cfg, err := p.adaptRequest(llm, req)
+		cfg, _, err := p.adaptRequest(llm, req)
 
 		assert.Nil(t, err)
 		assert.EqualValues(t, 10, cfg.N.Value)
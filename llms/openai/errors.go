@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/cockroachdb/errors"
+	"github.com/openai/openai-go"
+)
+
+// wrapError converts an error returned by the SDK into a provider error,
+// attributed to the system the provider sends requests to.
+func (p *OpenAi) wrapError(err error) error {
+	providerErr := llmberjack.ProviderError{
+		Provider: p.SystemName(),
+		Err:      err,
+	}
+
+	var apiErr *openai.Error
+
+	if errors.As(err, &apiErr) {
+		providerErr.StatusCode = apiErr.StatusCode
+		providerErr.Code = apiErr.Code
+		providerErr.Message = apiErr.Message
+
+		switch apiErr.Code {
+		case "context_length_exceeded", "string_above_max_length":
+			providerErr.Kind = llmberjack.ErrContextLengthExceeded
+		case "content_filter", "content_policy_violation":
+			providerErr.Kind = llmberjack.ErrContentFiltered
+		}
+
+		if apiErr.Response != nil {
+			providerErr.RetryAfter = retryAfter(apiErr.Response.Header)
+		}
+	}
+
+	return &providerErr
+}
+
+// retryAfter parses the delay the API asked to wait for before retrying, from
+// either the `Retry-After-Ms` or the `Retry-After` headers.
+func retryAfter(headers http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(headers.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := headers.Get("Retry-After")
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/cockroachdb/errors"
+	"github.com/openai/openai-go"
+	"github.com/samber/lo"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// ThreadHistory describes the messages of the history of a thread, with their
+// tokens counted as `CountTokens` does, with the tokenizer of the default model
+// of the provider.
+func (p *OpenAi) ThreadHistory(threadId *llmberjack.ThreadId) []llmberjack.HistoryMessage {
+	codec, _ := internal.Tokenizer(lo.FromPtr(p.model))
+
+	return p.history.Describe(threadId, func(msg openai.ChatCompletionMessageParamUnion) llmberjack.HistoryMessage {
+		return describeMessage(codec, msg)
+	})
+}
+
+// CompactThread removes the oldest messages of a thread, replacing them with a
+// user message containing the summary, if provided.
+func (p *OpenAi) CompactThread(threadId *llmberjack.ThreadId, count int, summary string) {
+	var replacement []openai.ChatCompletionMessageParamUnion
+
+	if summary != "" {
+		replacement = append(replacement, openai.UserMessage(summary))
+	}
+
+	p.history.Compact(threadId, count, isInstruction, replacement...)
+}
+
+func isInstruction(msg openai.ChatCompletionMessageParamUnion) bool {
+	return msg.OfSystem != nil || msg.OfDeveloper != nil
+}
+
+func describeMessage(codec tokenizer.Codec, msg openai.ChatCompletionMessageParamUnion) llmberjack.HistoryMessage {
+	var counted countedMessage
+
+	if buf, err := json.Marshal(msg); err == nil {
+		_ = json.Unmarshal(buf, &counted)
+	}
+
+	description := llmberjack.HistoryMessage{
+		Tokens: counted.tokens(codec),
+	}
+
+	switch counted.Role {
+	case "system", "developer":
+		description.Role = llmberjack.RoleSystem
+	case "assistant":
+		description.Role = llmberjack.RoleAi
+	case "tool", "function":
+		description.Role = llmberjack.RoleTool
+	default:
+		description.Role = llmberjack.RoleUser
+	}
+
+	text := make([]string, 0)
+
+	for _, part := range counted.parts() {
+		switch part.Type {
+		case "text":
+			text = append(text, part.Text)
+		case "image_url":
+			text = append(text, "[image]")
+		case "file":
+			text = append(text, "[document]")
+		case "input_audio":
+			text = append(text, "[audio]")
+		}
+	}
+
+	if len(counted.ToolCalls) > 0 && string(counted.ToolCalls) != "null" {
+		text = append(text, "[tool calls] "+string(counted.ToolCalls))
+	}
+
+	description.Text = strings.Join(text, "\n")
+
+	return description
+}
+
+// SaveInputs adds the messages of a request to its thread history without
+// sending it, for responses served from the cache.
+func (p *OpenAi) SaveInputs(llm internal.Adapter, requester llmberjack.Requester) error {
+	_, inputs, err := p.adaptRequest(llm, requester)
+	if err != nil {
+		return errors.Wrap(err, "could not adapt request")
+	}
+
+	p.saveInputs(requester, inputs)
+
+	return nil
+}
+
+// SaveOutput adds a candidate to the thread history of a request, for responses
+// served from the cache.
+func (p *OpenAi) SaveOutput(requester llmberjack.Requester, candidate llmberjack.ResponseCandidate) {
+	req := requester.ToRequest()
+
+	if req.ThreadId == nil || req.SkipSaveOutput {
+		return
+	}
+
+	msg := openai.ChatCompletionAssistantMessageParam{
+		Content: openai.ChatCompletionAssistantMessageParamContentUnion{
+			OfString: openai.String(candidate.Text),
+		},
+		ToolCalls: lo.Map(candidate.ToolCalls, func(call llmberjack.ResponseToolCall, _ int) openai.ChatCompletionMessageToolCallParam {
+			return openai.ChatCompletionMessageToolCallParam{
+				ID: call.Id,
+				Function: openai.ChatCompletionMessageToolCallFunctionParam{
+					Name:      call.Name,
+					Arguments: string(call.Parameters),
+				},
+			}
+		}),
+	}
+
+	p.history.Save(req.ThreadId, openai.ChatCompletionMessageParamUnion{OfAssistant: &msg})
+}
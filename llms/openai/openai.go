@@ -2,7 +2,9 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"reflect"
 	"time"
@@ -15,6 +17,14 @@ import (
 	"github.com/samber/lo"
 )
 
+// maxStopSequences is the maximum number of stop sequences accepted by the Chat
+// Completions API.
+const maxStopSequences = 4
+
+// maxTopLogprobs is the maximum number of alternatives to each token the Chat
+// Completions API can return.
+const maxTopLogprobs = 20
+
 type OpenAi struct {
 	client  openai.Client
 	history llmberjack.History[openai.ChatCompletionMessageParamUnion]
@@ -22,15 +32,26 @@ type OpenAi struct {
 	RequestHookFunc  func(llmberjack.Requester, *openai.ChatCompletionNewParams) error
 	ResponseHookFunc func(*openai.ChatCompletion, *llmberjack.InnerResponse) error
 
-	baseUrl string
-	apiKey  string
-	model   *string
+	baseUrl    string
+	apiKey     string
+	model      *string
+	systemName string
 }
 
 func (*OpenAi) RequestOptionsType() reflect.Type {
 	return nil
 }
 
+func (p *OpenAi) DefaultModel() string {
+	return lo.FromPtr(p.model)
+}
+
+// SystemName names the API requests are sent to, "openai" unless another one
+// was set with `WithSystemName()`.
+func (p *OpenAi) SystemName() string {
+	return lo.CoalesceOrEmpty(p.systemName, "openai")
+}
+
 func New(opts ...Opt) (*OpenAi, error) {
 	llm := OpenAi{}
 
@@ -44,6 +65,8 @@ func New(opts ...Opt) (*OpenAi, error) {
 func (p *OpenAi) Init(llm internal.Adapter) error {
 	opts := []option.RequestOption{
 		option.WithAPIKey(p.apiKey),
+		// Retries are handled by the adapter's retry policy.
+		option.WithMaxRetries(0),
 	}
 
 	if llm.HttpClient() != nil {
@@ -71,7 +94,7 @@ func (p *OpenAi) CloseThread(threadId *llmberjack.ThreadId) {
 }
 
 func (p *OpenAi) ChatCompletion(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester) (*llmberjack.InnerResponse, error) {
-	cfg, err := p.adaptRequest(llm, requester)
+	cfg, inputs, err := p.adaptRequest(llm, requester)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not adapt request")
 	}
@@ -84,7 +107,7 @@ func (p *OpenAi) ChatCompletion(ctx context.Context, llm internal.Adapter, reque
 
 	response, err := p.client.Chat.Completions.New(ctx, *cfg)
 	if err != nil {
-		return nil, errors.Wrap(err, "LLM provider failed to generate content")
+		return nil, errors.Wrap(p.wrapError(err), "LLM provider failed to generate content")
 	}
 
 	responseAdapter, err := p.adaptResponse(llm, response, requester)
@@ -98,12 +121,123 @@ func (p *OpenAi) ChatCompletion(ctx context.Context, llm internal.Adapter, reque
 		}
 	}
 
+	p.saveInputs(requester, inputs)
+
 	return responseAdapter, nil
 }
 
-func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Requester) (*openai.ChatCompletionNewParams, error) {
+func (p *OpenAi) ChatCompletionStream(ctx context.Context, llm internal.Adapter, requester llmberjack.Requester, onDelta func(llmberjack.StreamDelta) bool) (*llmberjack.InnerResponse, error) {
+	cfg, inputs, err := p.adaptRequest(llm, requester)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not adapt request")
+	}
+
+	if p.RequestHookFunc != nil {
+		if err := p.RequestHookFunc(requester, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	stream := p.client.Chat.Completions.NewStreaming(ctx, *cfg)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+
+	for stream.Next() {
+		chunk := stream.Current()
+
+		if !acc.AddChunk(chunk) {
+			return nil, errors.New("could not accumulate response chunk")
+		}
+
+		// The accumulator does not keep token details, but usage is only sent
+		// once, in the last chunk.
+		if chunk.JSON.Usage.Valid() {
+			acc.Usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			delta := llmberjack.StreamDelta{
+				Candidate: int(choice.Index),
+				Text:      choice.Delta.Content,
+				ToolCalls: lo.Map(choice.Delta.ToolCalls, func(t openai.ChatCompletionChunkChoiceDeltaToolCall, _ int) llmberjack.StreamToolCallDelta {
+					return llmberjack.StreamToolCallDelta{
+						Index:      int(t.Index),
+						Id:         t.ID,
+						Name:       t.Function.Name,
+						Parameters: t.Function.Arguments,
+					}
+				}),
+			}
+
+			if delta.Text == "" && len(delta.ToolCalls) == 0 {
+				continue
+			}
+
+			if !onDelta(delta) {
+				return nil, llmberjack.ErrStreamStopped
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, errors.Wrap(p.wrapError(err), "LLM provider failed to generate content")
+	}
+
+	responseAdapter, err := p.adaptResponse(llm, &acc.ChatCompletion, requester)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not adapt response")
+	}
+
+	if p.ResponseHookFunc != nil {
+		if err := p.ResponseHookFunc(&acc.ChatCompletion, responseAdapter); err != nil {
+			return nil, err
+		}
+	}
+
+	p.saveInputs(requester, inputs)
+
+	return responseAdapter, nil
+}
+
+// Render returns the payload that would be sent to the Chat Completions API for
+// the request, including the changes made by the request hook.
+func (p *OpenAi) Render(llm internal.Adapter, requester llmberjack.Requester) (json.RawMessage, error) {
+	cfg, _, err := p.adaptRequest(llm, requester)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not adapt request")
+	}
+
+	if p.RequestHookFunc != nil {
+		if err := p.RequestHookFunc(requester, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(cfg)
+}
+
+// saveInputs adds the messages sent in a request to its thread history. It is
+// only called once the provider successfully responded, so failed attempts do
+// not pollute the history.
+func (p *OpenAi) saveInputs(requester llmberjack.Requester, inputs []openai.ChatCompletionMessageParamUnion) {
+	r := requester.ToRequest()
+
+	if r.ThreadId != nil && !r.SkipSaveInput {
+		p.history.Save(r.ThreadId, inputs...)
+	}
+}
+
+// adaptRequest converts a request into the provider's format. It also returns
+// the messages from the request that should be added to the thread history.
+func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Requester) (*openai.ChatCompletionNewParams, []openai.ChatCompletionMessageParamUnion, error) {
 	r := requester.ToRequest()
 	contents := make([]openai.ChatCompletionMessageParamUnion, 0, len(r.Messages))
+	inputs := make([]openai.ChatCompletionMessageParamUnion, 0, len(r.Messages))
 
 	if r.ThreadId != nil {
 		contents = append(contents, p.history.Load(r.ThreadId)...)
@@ -111,7 +245,7 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 
 	model, ok := lo.Coalesce(r.Model, p.model, lo.ToPtr(llm.DefaultModel()))
 	if !ok {
-		return nil, errors.New("no model was configured")
+		return nil, nil, errors.New("no model was configured")
 	}
 
 	cfg := openai.ChatCompletionNewParams{
@@ -131,6 +265,29 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 	if r.TopP != nil {
 		cfg.TopP = openai.Float(*r.TopP)
 	}
+	if len(r.StopSequences) > maxStopSequences {
+		return nil, nil, errors.Wrapf(llmberjack.ErrUnsupportedOption, "OpenAI accepts at most %d stop sequences", maxStopSequences)
+	}
+	if len(r.StopSequences) > 0 {
+		cfg.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: r.StopSequences}
+	}
+	if r.Seed != nil {
+		cfg.Seed = openai.Int(int64(*r.Seed))
+	}
+	if r.PresencePenalty != nil {
+		cfg.PresencePenalty = openai.Float(*r.PresencePenalty)
+	}
+	if r.FrequencyPenalty != nil {
+		cfg.FrequencyPenalty = openai.Float(*r.FrequencyPenalty)
+	}
+	if r.Logprobs != nil {
+		if *r.Logprobs > maxTopLogprobs {
+			return nil, nil, errors.Wrapf(llmberjack.ErrUnsupportedOption, "OpenAI returns at most %d log probabilities per token", maxTopLogprobs)
+		}
+
+		cfg.Logprobs = openai.Bool(true)
+		cfg.TopLogprobs = openai.Int(int64(*r.Logprobs))
+	}
 
 	if r.ResponseSchema != nil {
 		cfg.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
@@ -148,13 +305,13 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 	for _, tool := range r.Tools {
 		paramsJson, err := json.Marshal(tool.Parameters)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to encode tool parameters")
+			return nil, nil, errors.Wrap(err, "failed to encode tool parameters")
 		}
 
 		var params map[string]any
 
 		if err := json.Unmarshal(paramsJson, &params); err != nil {
-			return nil, errors.Wrap(err, "failed to encode tool parameters")
+			return nil, nil, errors.Wrap(err, "failed to encode tool parameters")
 		}
 
 		cfg.Tools = append(cfg.Tools, openai.ChatCompletionToolParam{
@@ -168,24 +325,14 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 	}
 
 	for _, msg := range r.Messages {
-		parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(msg.Parts))
-
-		for _, part := range msg.Parts {
-			if seeker, ok := part.(io.ReadSeeker); ok {
-				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
-					return nil, err
-				}
-			}
-
-			buf, err := io.ReadAll(part)
-			if err != nil {
-				return nil, errors.Wrap(err, "could not read content part")
-			}
+		parts, err := adaptParts(msg)
+		if err != nil {
+			return nil, nil, err
+		}
 
-			switch msg.Type {
-			case llmberjack.TypeText:
-				parts = append(parts, openai.TextContentPart(string(buf)))
-			}
+		// Only user messages can contain other content than text.
+		if msg.Role != llmberjack.RoleUser && msg.Type != llmberjack.TypeText {
+			return nil, nil, errors.Wrap(llmberjack.ErrUnsupportedContent, "OpenAI only accepts text in non-user messages")
 		}
 
 		content := openai.ChatCompletionMessageParamUnion{}
@@ -207,13 +354,7 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 		case llmberjack.RoleUser:
 			content.OfUser = &openai.ChatCompletionUserMessageParam{
 				Content: openai.ChatCompletionUserMessageParamContentUnion{
-					OfArrayOfContentParts: lo.Map(parts, func(p openai.ChatCompletionContentPartUnionParam, _ int) openai.ChatCompletionContentPartUnionParam {
-						return openai.ChatCompletionContentPartUnionParam{
-							OfText: &openai.ChatCompletionContentPartTextParam{
-								Text: *p.GetText(),
-							},
-						}
-					}),
+					OfArrayOfContentParts: parts,
 				},
 			}
 
@@ -241,14 +382,95 @@ func (p *OpenAi) adaptRequest(llm internal.Adapter, requester llmberjack.Request
 			}
 		}
 
-		if r.ThreadId != nil && !r.SkipSaveInput {
-			p.history.Save(r.ThreadId, content)
+		inputs = append(inputs, content)
+		cfg.Messages = append(cfg.Messages, content)
+	}
+
+	return &cfg, inputs, nil
+}
+
+// adaptParts converts the content of a message into OpenAI content parts.
+func adaptParts(msg llmberjack.Message) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	if msg.Url != "" {
+		if msg.Type != llmberjack.TypeImage {
+			return nil, errors.Wrap(llmberjack.ErrUnsupportedContent, "OpenAI only accepts images by URL")
 		}
 
-		cfg.Messages = append(cfg.Messages, content)
+		return []openai.ChatCompletionContentPartUnionParam{
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: msg.Url}),
+		}, nil
+	}
+
+	if msg.Type == llmberjack.TypeDocument && msg.MimeType != "application/pdf" {
+		return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "OpenAI does not accept documents of type '%s'", msg.MimeType)
+	}
+
+	var audioFormat string
+
+	if msg.Type == llmberjack.TypeAudio {
+		format, ok := audioFormats[msg.MimeType]
+		if !ok {
+			return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "OpenAI does not accept audio of type '%s'", msg.MimeType)
+		}
+
+		audioFormat = format
+	}
+
+	parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(msg.Parts))
+
+	for _, part := range msg.Parts {
+		if seeker, ok := part.(io.ReadSeeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+
+		buf, err := io.ReadAll(part)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read content part")
+		}
+
+		switch msg.Type {
+		case llmberjack.TypeText:
+			parts = append(parts, openai.TextContentPart(string(buf)))
+		case llmberjack.TypeImage:
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: dataUrl(msg.MimeType, buf),
+			}))
+		case llmberjack.TypeDocument:
+			file := openai.ChatCompletionContentPartFileFileParam{
+				FileData: openai.String(dataUrl(msg.MimeType, buf)),
+			}
+			if msg.Filename != "" {
+				file.Filename = openai.String(msg.Filename)
+			}
+
+			parts = append(parts, openai.FileContentPart(file))
+		case llmberjack.TypeAudio:
+			parts = append(parts, openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+				Data:   base64.StdEncoding.EncodeToString(buf),
+				Format: audioFormat,
+			}))
+		default:
+			return nil, errors.Wrapf(llmberjack.ErrUnsupportedContent, "OpenAI does not accept messages of type %d", msg.Type)
+		}
 	}
 
-	return &cfg, nil
+	return parts, nil
+}
+
+// audioFormats maps the MIME types of the audio formats accepted by OpenAI to
+// their format names.
+var audioFormats = map[string]string{
+	"audio/wav":   "wav",
+	"audio/wave":  "wav",
+	"audio/x-wav": "wav",
+	"audio/mpeg":  "mp3",
+	"audio/mp3":   "mp3",
+}
+
+func dataUrl(mimeType string, content []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(content))
 }
 
 func (p *OpenAi) adaptResponse(_ internal.Adapter, response *openai.ChatCompletion, requester llmberjack.Requester) (*llmberjack.InnerResponse, error) {
@@ -257,6 +479,13 @@ func (p *OpenAi) adaptResponse(_ internal.Adapter, response *openai.ChatCompleti
 		Model:      response.Model,
 		Candidates: make([]llmberjack.ResponseCandidate, len(response.Choices)),
 		Created:    time.Unix(response.Created, 0),
+		Usage: llmberjack.Usage{
+			PromptTokens:     int(response.Usage.PromptTokens),
+			CompletionTokens: int(response.Usage.CompletionTokens),
+			CachedTokens:     int(response.Usage.PromptTokensDetails.CachedTokens),
+			ReasoningTokens:  int(response.Usage.CompletionTokensDetails.ReasoningTokens),
+			TotalTokens:      int(response.Usage.TotalTokens),
+		},
 	}
 
 	for idx, candidate := range response.Choices {
@@ -287,6 +516,7 @@ func (p *OpenAi) adaptResponse(_ internal.Adapter, response *openai.ChatCompleti
 			Text:         candidate.Message.Content,
 			ToolCalls:    toolCalls,
 			FinishReason: finishReason,
+			Logprobs:     adaptLogprobs(candidate.Logprobs.Content),
 			SelectCandidate: func() {
 				req := requester.ToRequest()
 
@@ -308,3 +538,35 @@ func (p *OpenAi) adaptResponse(_ internal.Adapter, response *openai.ChatCompleti
 
 	return &resp, nil
 }
+
+// adaptLogprobs converts the log probabilities of the tokens of a choice. Tokens
+// are rebuilt from their bytes when available, since characters can be split
+// across several tokens.
+func adaptLogprobs(logprobs []openai.ChatCompletionTokenLogprob) []llmberjack.TokenLogprob {
+	if len(logprobs) == 0 {
+		return nil
+	}
+
+	return lo.Map(logprobs, func(l openai.ChatCompletionTokenLogprob, _ int) llmberjack.TokenLogprob {
+		return llmberjack.TokenLogprob{
+			Token:   tokenText(l.Token, l.Bytes),
+			Logprob: l.Logprob,
+			Alternatives: lo.Map(l.TopLogprobs, func(a openai.ChatCompletionTokenLogprobTopLogprob, _ int) llmberjack.TokenAlternative {
+				return llmberjack.TokenAlternative{
+					Token:   tokenText(a.Token, a.Bytes),
+					Logprob: a.Logprob,
+				}
+			}),
+		}
+	})
+}
+
+func tokenText(token string, bytes []int64) string {
+	if len(bytes) == 0 {
+		return token
+	}
+
+	return string(lo.Map(bytes, func(b int64, _ int) byte {
+		return byte(b)
+	}))
+}
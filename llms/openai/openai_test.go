@@ -9,6 +9,7 @@ import (
 
 	llmberjack "github.com/checkmarble/llmberjack"
 	"github.com/checkmarble/llmberjack/llms/openai"
+	"github.com/cockroachdb/errors"
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
@@ -28,9 +29,30 @@ const openaiResponse = `{
 			}
 		}
 	],
-	"created": 1752423600
+	"created": 1752423600,
+	"usage": {
+		"prompt_tokens": 100,
+		"completion_tokens": 50,
+		"total_tokens": 150,
+		"prompt_tokens_details": { "cached_tokens": 20 },
+		"completion_tokens_details": { "reasoning_tokens": 10 }
+	}
 }`
 
+func TestOpenAiSystemName(t *testing.T) {
+	provider, _ := openai.New()
+
+	assert.Equal(t, "openai", provider.SystemName())
+}
+
+func TestOpenAiDefaultModel(t *testing.T) {
+	provider, _ := openai.New()
+	withModel, _ := openai.New(openai.WithDefaultModel("gpt-4o"))
+
+	assert.Equal(t, "", provider.DefaultModel())
+	assert.Equal(t, "gpt-4o", withModel.DefaultModel())
+}
+
 func TestOpenAiRequest(t *testing.T) {
 	defer gock.Off()
 
@@ -100,6 +122,7 @@ func TestOpenAiRequest(t *testing.T) {
 	assert.Equal(t, "theid", resp.Id)
 	assert.WithinDuration(t, time.Date(2025, 7, 13, 16, 20, 0, 0, time.UTC), resp.Created, 0)
 	assert.Equal(t, 1, resp.NumCandidates())
+	assert.Equal(t, llmberjack.Usage{PromptTokens: 100, CompletionTokens: 50, CachedTokens: 20, ReasoningTokens: 10, TotalTokens: 150}, resp.Usage)
 
 	candidate, err := resp.Candidate(0)
 
@@ -111,3 +134,533 @@ func TestOpenAiRequest(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "The JSON response from the provider.", output.Reply)
 }
+
+func TestOpenAiStream(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			assert.True(t, gjson.GetBytes(body, "stream").Bool())
+			assert.True(t, gjson.GetBytes(body, "stream_options.include_usage").Bool())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "text/event-stream").
+		BodyString(`data: {"id":"theid","object":"chat.completion.chunk","created":1752423600,"model":"themodel","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]}
+
+data: {"id":"theid","object":"chat.completion.chunk","created":1752423600,"model":"themodel","choices":[{"index":0,"delta":{"content":", world!"},"finish_reason":null}]}
+
+data: {"id":"theid","object":"chat.completion.chunk","created":1752423600,"model":"themodel","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"thetool","arguments":"{\"name\":"}}]},"finish_reason":null}]}
+
+data: {"id":"theid","object":"chat.completion.chunk","created":1752423600,"model":"themodel","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Bob\"}"}}]},"finish_reason":"stop"}]}
+
+data: {"id":"theid","object":"chat.completion.chunk","created":1752423600,"model":"themodel","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"prompt_tokens_details":{"cached_tokens":2}}}
+
+data: [DONE]
+
+`)
+
+	stream, response := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithText(llmberjack.RoleUser, "user text").
+		Stream(t.Context(), llm)
+
+	deltas := []llmberjack.StreamDelta{}
+
+	for delta, err := range stream {
+		assert.Nil(t, err)
+
+		deltas = append(deltas, delta)
+	}
+
+	assert.False(t, gock.HasUnmatchedRequest())
+	assert.Len(t, deltas, 4)
+	assert.Equal(t, "Hello", deltas[0].Text)
+	assert.Equal(t, ", world!", deltas[1].Text)
+	assert.Equal(t, "thetool", deltas[2].ToolCalls[0].Name)
+	assert.Equal(t, `"Bob"}`, deltas[3].ToolCalls[0].Parameters)
+
+	resp, err := response()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "theid", resp.Id)
+	assert.Equal(t, "themodel", resp.Model)
+	assert.Equal(t, 1, resp.NumCandidates())
+	assert.Equal(t, "Hello, world!", resp.Candidates[0].Text)
+	assert.Equal(t, llmberjack.FinishReasonStop, resp.Candidates[0].FinishReason)
+	assert.Len(t, resp.Candidates[0].ToolCalls, 1)
+	assert.Equal(t, "call_1", resp.Candidates[0].ToolCalls[0].Id)
+	assert.JSONEq(t, `{"name":"Bob"}`, string(resp.Candidates[0].ToolCalls[0].Parameters))
+	assert.Equal(t, llmberjack.Usage{PromptTokens: 10, CompletionTokens: 5, CachedTokens: 2, TotalTokens: 15}, resp.Usage)
+}
+
+func TestOpenAiRetry(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(provider),
+		llmberjack.WithRetryPolicy(llmberjack.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusTooManyRequests).
+		SetHeader("retry-after-ms", "1").
+		SetHeader("content-type", "application/json").
+		BodyString(`{"error":{"message":"Rate limited","type":"rate_limit_error"}}`)
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(openaiResponse)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		CreateThread().
+		WithText(llmberjack.RoleUser, "user text").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			// The input from the retried request must only be present once.
+			assert.EqualValues(t, 3, gjson.GetBytes(body, "messages.#").Int())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(openaiResponse)
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		FromCandidate(resp, 0).
+		WithText(llmberjack.RoleUser, "other text").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiProviderError(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusServiceUnavailable).
+		SetHeader("retry-after", "30").
+		SetHeader("content-type", "application/json").
+		BodyString(`{"error":{"message":"Overloaded","type":"server_error"}}`)
+
+	_, err := llmberjack.NewUntypedRequest().WithModel("themodel").Do(t.Context(), llm)
+
+	var providerErr *llmberjack.ProviderError
+
+	assert.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, http.StatusServiceUnavailable, providerErr.StatusCode)
+	assert.Equal(t, 30*time.Second, providerErr.RetryAfter)
+	assert.True(t, llmberjack.IsRetryable(err))
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiErrorKinds(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	tests := []struct {
+		status   int
+		body     string
+		expected error
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`, llmberjack.ErrAuthentication},
+		{http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`, llmberjack.ErrRateLimited},
+		{http.StatusBadRequest, `{"error":{"message":"Maximum context length exceeded","type":"invalid_request_error","code":"context_length_exceeded"}}`, llmberjack.ErrContextLengthExceeded},
+		{http.StatusBadRequest, `{"error":{"message":"Content filtered","type":"invalid_request_error","code":"content_filter"}}`, llmberjack.ErrContentFiltered},
+		{http.StatusBadRequest, `{"error":{"message":"Invalid parameter","type":"invalid_request_error","code":"invalid_value"}}`, llmberjack.ErrInvalidRequest},
+		{http.StatusBadGateway, `{"error":{"message":"Bad gateway","type":"server_error"}}`, llmberjack.ErrProviderUnavailable},
+	}
+
+	for _, tt := range tests {
+		gock.New("https://api.openai.com").
+			Post("/v1/chat/completions").
+			Reply(tt.status).
+			SetHeader("content-type", "application/json").
+			BodyString(tt.body)
+
+		_, err := llmberjack.NewUntypedRequest().WithModel("themodel").Do(t.Context(), llm)
+
+		assert.ErrorIs(t, err, tt.expected)
+
+		var providerErr *llmberjack.ProviderError
+
+		assert.True(t, errors.As(err, &providerErr))
+		assert.Equal(t, "openai", providerErr.Provider)
+		assert.Equal(t, tt.status, providerErr.StatusCode)
+		assert.NotEmpty(t, providerErr.Message)
+	}
+
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiAudioInThread(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	for range 2 {
+		gock.New("https://api.openai.com").
+			Post("/v1/chat/completions").
+			AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+				body, _ := io.ReadAll(req.Body)
+
+				assert.Equal(t, "input_audio", gjson.GetBytes(body, "messages.0.content.0.type").String())
+				assert.Equal(t, "YXVkaW8=", gjson.GetBytes(body, "messages.0.content.0.input_audio.data").String())
+				assert.Equal(t, "wav", gjson.GetBytes(body, "messages.0.content.0.input_audio.format").String())
+
+				return true, nil
+			}).
+			Reply(http.StatusOK).
+			SetHeader("content-type", "application/json").BodyString(openaiResponse)
+	}
+
+	// The recording can only be read once, so the second turn must reuse the
+	// content stored in the history of the thread.
+	audio := io.MultiReader(strings.NewReader("audio"))
+
+	resp, err := llmberjack.NewUntypedRequest().
+		CreateThread().
+		WithAudio(llmberjack.RoleUser, audio, "audio/wav").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	_, err = llmberjack.NewUntypedRequest().
+		InThread(resp.ThreadId).
+		WithText(llmberjack.RoleUser, "What was said?").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.False(t, gock.HasUnmatchedRequest())
+}
+
+func TestOpenAiRender(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").BodyString(openaiResponse)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		CreateThread().
+		WithText(llmberjack.RoleUser, "first prompt").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	req := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		InThread(resp.ThreadId).
+		WithTemperature(0.5).
+		WithText(llmberjack.RoleUser, "second prompt")
+
+	// Rendering twice checks the history of the thread was left untouched.
+	for range 2 {
+		payload, err := req.Render(llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "themodel", gjson.GetBytes(payload, "model").String())
+		assert.Equal(t, 0.5, gjson.GetBytes(payload, "temperature").Float())
+		assert.EqualValues(t, 2, gjson.GetBytes(payload, "messages.#").Int())
+		assert.Equal(t, "first prompt", gjson.GetBytes(payload, "messages.0.content.0.text").String())
+		assert.Equal(t, "second prompt", gjson.GetBytes(payload, "messages.1.content.0.text").String())
+	}
+
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiCacheInThread(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(
+		llmberjack.WithDefaultProvider(provider),
+		llmberjack.WithCache(llmberjack.NewMemoryCache(10), time.Hour),
+	)
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").BodyString(openaiResponse)
+
+	var threadId *llmberjack.ThreadId
+
+	for _, cached := range []bool{false, true} {
+		resp, err := llmberjack.NewUntypedRequest().
+			WithModel("themodel").
+			CreateThread().
+			CacheInThread().
+			WithText(llmberjack.RoleUser, "first prompt").
+			Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, cached, resp.Cached)
+
+		resp.Candidates[0].SelectCandidate()
+		threadId = resp.ThreadId
+	}
+
+	// The cached response was added to the history of the second thread.
+	payload, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		InThread(threadId).
+		WithText(llmberjack.RoleUser, "second prompt").
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, gjson.GetBytes(payload, "messages.#").Int())
+	assert.Equal(t, "first prompt", gjson.GetBytes(payload, "messages.0.content.0.text").String())
+	assert.Equal(t, "assistant", gjson.GetBytes(payload, "messages.1.role").String())
+	assert.Equal(t, `{"reply":"The JSON response from the provider."}`, gjson.GetBytes(payload, "messages.1.content").String())
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiCountTokens(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").BodyString(openaiResponse)
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		CreateThread().
+		WithText(llmberjack.RoleUser, "first prompt").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	short, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithText(llmberjack.RoleUser, "second prompt").
+		CountTokens(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, short.Estimated)
+	assert.Positive(t, short.Tokens)
+
+	// Text is counted exactly for known models: 3 tokens per message, 1 for
+	// the role, 2 for "hello world", and 3 for the reply.
+	exact, err := llmberjack.NewUntypedRequest().
+		WithModel("gpt-4o").
+		WithText(llmberjack.RoleUser, "hello world").
+		CountTokens(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.False(t, exact.Estimated)
+	assert.Equal(t, 9, exact.Tokens)
+
+	// The history of the thread is counted, and left untouched.
+	for range 2 {
+		long, err := llmberjack.NewUntypedRequest().
+			WithModel("themodel").
+			InThread(resp.ThreadId).
+			WithInstruction("You are a helpful assistant.").
+			WithText(llmberjack.RoleUser, "second prompt").
+			CountTokens(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Greater(t, long.Tokens, short.Tokens)
+	}
+
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiHistoryPolicy(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	for range 2 {
+		gock.New("https://api.openai.com").
+			Post("/v1/chat/completions").
+			Reply(http.StatusOK).
+			SetHeader("content-type", "application/json").BodyString(openaiResponse)
+	}
+
+	resp, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		CreateThread().
+		WithInstruction("system text").
+		WithText(llmberjack.RoleUser, "first prompt").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	resp, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		FromCandidate(resp, 0).
+		WithText(llmberjack.RoleUser, "second prompt").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	history := provider.ThreadHistory(resp.ThreadId)
+
+	assert.Len(t, history, 4)
+	assert.Equal(t, llmberjack.RoleSystem, history[0].Role)
+	assert.Equal(t, llmberjack.RoleAi, history[2].Role)
+	assert.Equal(t, "second prompt", history[3].Text)
+	assert.Positive(t, history[3].Tokens)
+
+	resp.ThreadId.SetHistoryPolicy(llmberjack.HistoryPolicy{MaxTurns: 1})
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			assert.EqualValues(t, 4, gjson.GetBytes(body, "messages.#").Int())
+			assert.Equal(t, "system", gjson.GetBytes(body, "messages.0.role").String())
+			assert.Equal(t, "second prompt", gjson.GetBytes(body, "messages.1.content.0.text").String())
+			assert.Equal(t, "assistant", gjson.GetBytes(body, "messages.2.role").String())
+			assert.Equal(t, "third prompt", gjson.GetBytes(body, "messages.3.content.0.text").String())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").BodyString(openaiResponse)
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		FromCandidate(resp, 0).
+		WithText(llmberjack.RoleUser, "third prompt").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestOpenAiSampling(t *testing.T) {
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	payload, err := llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithStopSequences("END", "STOP").
+		WithSeed(42).
+		WithPresencePenalty(0.5).
+		WithFrequencyPenalty(-0.5).
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `["END","STOP"]`, gjson.GetBytes(payload, "stop").Raw)
+	assert.EqualValues(t, 42, gjson.GetBytes(payload, "seed").Int())
+	assert.Equal(t, 0.5, gjson.GetBytes(payload, "presence_penalty").Float())
+	assert.Equal(t, -0.5, gjson.GetBytes(payload, "frequency_penalty").Float())
+
+	_, err = llmberjack.NewUntypedRequest().
+		WithModel("themodel").
+		WithStopSequences("1", "2", "3", "4", "5").
+		Render(llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+	assert.ErrorContains(t, err, "OpenAI accepts at most 4 stop sequences")
+}
+
+func TestOpenAiLogprobs(t *testing.T) {
+	defer gock.Off()
+
+	type Output struct {
+		Label string `json:"label"`
+	}
+
+	provider, _ := openai.New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.openai.com").
+		Post("/v1/chat/completions").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, _ := io.ReadAll(req.Body)
+
+			assert.True(t, gjson.GetBytes(body, "logprobs").Bool())
+			assert.EqualValues(t, 2, gjson.GetBytes(body, "top_logprobs").Int())
+
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		SetHeader("content-type", "application/json").
+		BodyString(`{
+			"id": "theid",
+			"model": "themodel",
+			"choices": [{
+				"index": 0,
+				"finish_reason": "stop",
+				"message": { "role": "assistant", "content": "{\"label\":\"fraud\"}" },
+				"logprobs": {
+					"content": [
+						{ "token": "{\"label\":\"", "bytes": null, "logprob": 0, "top_logprobs": [] },
+						{ "token": "fraud", "bytes": [102, 114, 97, 117, 100], "logprob": -0.1, "top_logprobs": [
+							{ "token": "fraud", "bytes": [102, 114, 97, 117, 100], "logprob": -0.1 },
+							{ "token": "legit", "bytes": [108, 101, 103, 105, 116], "logprob": -2.4 }
+						] },
+						{ "token": "\"}", "bytes": null, "logprob": 0, "top_logprobs": [] }
+					]
+				}
+			}],
+			"created": 1752423600
+		}`)
+
+	resp, err := llmberjack.NewRequest[Output]().
+		WithModel("themodel").
+		WithLogprobs(2).
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	candidate, _ := resp.Candidate(0)
+
+	assert.Len(t, candidate.Logprobs, 3)
+	assert.Equal(t, "fraud", candidate.Logprobs[1].Token)
+	assert.Equal(t, -0.1, candidate.Logprobs[1].Logprob)
+	assert.Equal(t, llmberjack.TokenAlternative{Token: "legit", Logprob: -2.4}, candidate.Logprobs[1].Alternatives[1])
+
+	label, probability, err := llmberjack.EnumProbability[string](candidate, "label")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "fraud", label)
+	assert.InDelta(t, 0.905, probability, 1e-3)
+
+	_, err = llmberjack.NewUntypedRequest().WithModel("themodel").WithLogprobs(21).Render(llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+}
@@ -11,6 +11,16 @@ func WithBaseUrl(url string) Opt {
 	}
 }
 
+// WithSystemName sets the name of the OpenAI-compatible API, reported in traces
+// and provider errors, and used to match pricing rates.
+//
+// If not specified, will use "openai".
+func WithSystemName(name string) Opt {
+	return func(p *OpenAi) {
+		p.systemName = name
+	}
+}
+
 func WithApiKey(apiKey string) Opt {
 	return func(p *OpenAi) {
 		p.apiKey = apiKey
@@ -0,0 +1,164 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+
+	llmberjack "github.com/checkmarble/llmberjack"
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+const (
+	// Every message is wrapped in special tokens delimiting it and its role,
+	// and the reply of the model is primed with a few more.
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+	// Images are counted as a high-detail 1024x1024 image.
+	tokensPerImage = 765
+	// Audio is counted at about 10 tokens per second, from the bitrate of
+	// 16kHz mono WAV and of 128kbps MP3.
+	wavBytesPerToken = 3200
+	mp3BytesPerToken = 1600
+	// Documents are counted from their size, as most of their content is
+	// extracted as text.
+	documentBytesPerToken = 4
+)
+
+type countedPayload struct {
+	Model          string           `json:"model"`
+	Messages       []countedMessage `json:"messages"`
+	Tools          json.RawMessage  `json:"tools"`
+	ResponseFormat json.RawMessage  `json:"response_format"`
+}
+
+type countedMessage struct {
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	ToolCalls json.RawMessage `json:"tool_calls"`
+}
+
+type countedPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	File struct {
+		FileData string `json:"file_data"`
+	} `json:"file"`
+	InputAudio struct {
+		Data   string `json:"data"`
+		Format string `json:"format"`
+	} `json:"input_audio"`
+}
+
+// CountTokens counts the input tokens of the request, without any network call.
+//
+// Text is encoded with the BPE tokenizer of the model (`o200k_base` or
+// `cl100k_base`), and messages are framed as the Chat Completions API does.
+// The count is only an estimate if the encoding of the model is unknown, or if
+// the request contains images, audio or documents, which are counted coarsely
+// from their size.
+func (p *OpenAi) CountTokens(_ context.Context, llm internal.Adapter, requester llmberjack.Requester) (llmberjack.TokenCount, error) {
+	cfg, _, err := p.adaptRequest(llm, requester)
+	if err != nil {
+		return llmberjack.TokenCount{}, errors.Wrap(err, "could not adapt request")
+	}
+
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		return llmberjack.TokenCount{}, errors.Wrap(err, "could not serialize request")
+	}
+
+	var payload countedPayload
+
+	if err := json.Unmarshal(buf, &payload); err != nil {
+		return llmberjack.TokenCount{}, errors.Wrap(err, "could not decode request")
+	}
+
+	codec, exact := internal.Tokenizer(payload.Model)
+	tokens := tokensPerReply
+
+	if len(payload.Tools) > 0 {
+		tokens += internal.CountTokens(codec, string(payload.Tools))
+	}
+	if len(payload.ResponseFormat) > 0 {
+		tokens += internal.CountTokens(codec, string(payload.ResponseFormat))
+	}
+
+	for _, msg := range payload.Messages {
+		tokens += msg.tokens(codec)
+
+		if msg.hasMedia() {
+			exact = false
+		}
+	}
+
+	return llmberjack.TokenCount{Tokens: tokens, Estimated: !exact}, nil
+}
+
+// parts returns the content parts of the message, its content being either a
+// string or an array of parts.
+func (m countedMessage) parts() []countedPart {
+	var text string
+
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return []countedPart{{Type: "text", Text: text}}
+	}
+
+	var parts []countedPart
+
+	_ = json.Unmarshal(m.Content, &parts)
+
+	return parts
+}
+
+// hasMedia checks whether the message contains images, audio or documents.
+func (m countedMessage) hasMedia() bool {
+	return lo.SomeBy(m.parts(), func(part countedPart) bool {
+		return part.Type != "text"
+	})
+}
+
+// tokens counts the tokens of the message, including its role and the tokens
+// delimiting it.
+func (m countedMessage) tokens(codec tokenizer.Codec) int {
+	tokens := tokensPerMessage + internal.CountTokens(codec, m.Role)
+
+	if len(m.ToolCalls) > 0 {
+		tokens += internal.CountTokens(codec, string(m.ToolCalls))
+	}
+
+	for _, part := range m.parts() {
+		switch part.Type {
+		case "text":
+			tokens += internal.CountTokens(codec, part.Text)
+		case "image_url":
+			tokens += tokensPerImage
+		case "file":
+			tokens += base64Size(part.File.FileData) / documentBytesPerToken
+		case "input_audio":
+			switch part.InputAudio.Format {
+			case "mp3":
+				tokens += base64Size(part.InputAudio.Data) / mp3BytesPerToken
+			default:
+				tokens += base64Size(part.InputAudio.Data) / wavBytesPerToken
+			}
+		}
+	}
+
+	return tokens
+}
+
+// base64Size returns the size of base64-encoded data, ignoring the prefix of
+// data URLs.
+func base64Size(data string) int {
+	if m := dataUrlPrefix.FindStringIndex(data); m != nil {
+		data = data[m[1]:]
+	}
+
+	return len(data) * 3 / 4
+}
+
+var dataUrlPrefix = regexp.MustCompile(`^data:[^,]*,`)
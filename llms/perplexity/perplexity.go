@@ -8,6 +8,7 @@ import (
 	llmberjack "github.com/checkmarble/llmberjack"
 	"github.com/checkmarble/llmberjack/internal"
 	base "github.com/checkmarble/llmberjack/llms/openai"
+	"github.com/cockroachdb/errors"
 	"github.com/fatih/structs"
 	"github.com/openai/openai-go"
 	"github.com/samber/lo"
@@ -24,6 +25,7 @@ func (*Perplexity) RequestOptionsType() reflect.Type {
 func New(openAiOpts ...base.Opt) (*Perplexity, error) {
 	oai, err := base.New(
 		base.WithBaseUrl("https://api.perplexity.ai"),
+		base.WithSystemName("perplexity"),
 	)
 
 	if err != nil {
@@ -45,6 +47,17 @@ func New(openAiOpts ...base.Opt) (*Perplexity, error) {
 }
 
 func (p *Perplexity) transformRequest(requester llmberjack.Requester, cfg *openai.ChatCompletionNewParams) error {
+	r := requester.ToRequest()
+
+	switch {
+	case len(r.StopSequences) > 0:
+		return errors.Wrap(llmberjack.ErrUnsupportedOption, "Perplexity does not support stop sequences")
+	case r.Seed != nil:
+		return errors.Wrap(llmberjack.ErrUnsupportedOption, "Perplexity does not support seeds")
+	case r.PresencePenalty != nil && r.FrequencyPenalty != nil:
+		return errors.Wrap(llmberjack.ErrUnsupportedOption, "Perplexity does not support presence and frequency penalties together")
+	}
+
 	opts := internal.CastProviderOptions[RequestOptions](requester.ProviderRequestOptions(p))
 
 	cfg.SetExtraFields(structs.Map(opts))
@@ -8,11 +8,39 @@ import (
 
 	llmberjack "github.com/checkmarble/llmberjack"
 	"github.com/checkmarble/llmberjack/llms/openai"
+	"github.com/cockroachdb/errors"
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
 )
 
+func TestPerplexitySystemName(t *testing.T) {
+	provider, _ := New()
+
+	assert.Equal(t, "perplexity", provider.SystemName())
+}
+
+func TestPerplexityProviderError(t *testing.T) {
+	defer gock.Off()
+
+	provider, _ := New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	gock.New("https://api.perplexity.ai").
+		Post("/chat/completions").
+		Reply(http.StatusUnauthorized).
+		SetHeader("content-type", "application/json").
+		BodyString(`{"error":{"message":"Invalid API key","type":"invalid_api_key","code":401}}`)
+
+	_, err := llmberjack.NewUntypedRequest().WithModel("sonar").Do(t.Context(), llm)
+
+	var providerErr *llmberjack.ProviderError
+
+	assert.ErrorIs(t, err, llmberjack.ErrAuthentication)
+	assert.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, "perplexity", providerErr.Provider)
+}
+
 func TestPerplexityExtras(t *testing.T) {
 	defer gock.Off()
 
@@ -95,6 +123,7 @@ func TestPerplexityExtras(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, "Vous savez, moi je ne crois pas qu’il y ait de bonne ou de mauvaise situation.", resp.Candidates[0].Text)
+	assert.Equal(t, llmberjack.Usage{PromptTokens: 79, CompletionTokens: 367, TotalTokens: 446}, resp.Usage)
 	assert.Equal(t, "Je n'en peux plus de ces papyrus là", resp.Candidates[0].Grounding.Sources[0].Title)
 	assert.Equal(t, "https://www.kaakook.fr/citation-1331", resp.Candidates[0].Grounding.Sources[0].Url)
 	date, _ := time.Parse(time.DateOnly, "2002-01-30")
@@ -105,3 +134,17 @@ func TestPerplexityExtras(t *testing.T) {
 
 	assert.False(t, gock.HasUnmatchedRequest())
 }
+
+func TestPerplexityUnsupportedSampling(t *testing.T) {
+	provider, _ := New(openai.WithApiKey("apikey"))
+	llm, _ := llmberjack.New(llmberjack.WithDefaultProvider(provider))
+
+	_, err := llmberjack.NewUntypedRequest().WithSeed(42).Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+	assert.ErrorContains(t, err, "Perplexity does not support seeds")
+
+	_, err = llmberjack.NewUntypedRequest().WithPresencePenalty(1).WithFrequencyPenalty(1).Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, llmberjack.ErrUnsupportedOption)
+}
@@ -0,0 +1,136 @@
+package llmberjack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+)
+
+// Redactor rewrites log attributes before they are emitted, to mask sensitive
+// data. It has the same signature as `slog.HandlerOptions.ReplaceAttr`.
+type Redactor func(groups []string, attr slog.Attr) slog.Attr
+
+const redacted = "[REDACTED]"
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:api[_-]?key|key|token|access_token)=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)(bearer\s+)[^\s"']+`),
+	regexp.MustCompile(`()\bsk-[A-Za-z0-9_-]{8,}`),
+	regexp.MustCompile(`()\bAIza[0-9A-Za-z_-]{20,}`),
+}
+
+// RedactSecrets masks anything looking like an API key or an access token in
+// the provided string.
+func RedactSecrets(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted)
+	}
+
+	return s
+}
+
+// DefaultRedactor masks the content of messages and responses, and API keys
+// that could appear in other attributes, such as errors.
+func DefaultRedactor(_ []string, attr slog.Attr) slog.Attr {
+	switch {
+	case attr.Key == "content":
+		return slog.String(attr.Key, redacted)
+	case attr.Value.Kind() == slog.KindString:
+		return slog.String(attr.Key, RedactSecrets(attr.Value.String()))
+	}
+
+	return attr
+}
+
+// log emits a log record, if a logger was configured, after passing its
+// attributes through the redactor.
+func (llm *Llmberjack) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if llm.logger == nil || !llm.logger.Enabled(ctx, level) {
+		return
+	}
+
+	redactor := llm.redactor
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
+
+	llm.logger.LogAttrs(ctx, level, msg, lo.Map(attrs, func(attr slog.Attr, _ int) slog.Attr {
+		return redactor(nil, attr)
+	})...)
+}
+
+// failureLevel returns the level at which a failed request is logged. Requests
+// interrupted by the caller are not errors.
+func failureLevel(err error) slog.Level {
+	switch {
+	case errors.Is(err, ErrStreamStopped):
+		return slog.LevelDebug
+	case errors.Is(err, context.Canceled):
+		return slog.LevelInfo
+	default:
+		return slog.LevelError
+	}
+}
+
+func threadAttr(threadId *ThreadId) slog.Attr {
+	if threadId == nil {
+		return slog.String("thread", "")
+	}
+
+	return slog.String("thread", fmt.Sprintf("%p", threadId))
+}
+
+// logRequest logs a request about to be sent to a provider. The content of
+// messages is only read when debug logs are enabled.
+func (llm *Llmberjack) logRequest(ctx context.Context, providerName, model string, req innerRequest) {
+	if llm.logger == nil || !llm.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	contents := make([]string, 0, len(req.Messages))
+
+	for _, msg := range req.Messages {
+		if msg.Url != "" {
+			contents = append(contents, msg.Url)
+		}
+
+		for _, part := range msg.Parts {
+			if content, ok := describePart(msg, part); ok {
+				contents = append(contents, content)
+			}
+		}
+	}
+
+	llm.log(ctx, slog.LevelDebug, "sending request to LLM provider",
+		slog.String("provider", providerName),
+		slog.String("model", model),
+		threadAttr(req.ThreadId),
+		slog.Int("messages", len(req.Messages)),
+		slog.Int("tools", len(req.Tools)),
+		slog.Any("content", contents),
+	)
+}
+
+// logResponse logs a response received from a provider.
+func (llm *Llmberjack) logResponse(ctx context.Context, providerName string, threadId *ThreadId, resp *InnerResponse, latency time.Duration) {
+	llm.log(ctx, slog.LevelInfo, "received response from LLM provider",
+		slog.String("provider", providerName),
+		slog.String("model", resp.Model),
+		threadAttr(threadId),
+		slog.Duration("latency", latency),
+		slog.Any("finish_reasons", lo.Map(resp.Candidates, func(c ResponseCandidate, _ int) string {
+			return string(c.FinishReason)
+		})),
+		slog.Any("tool_calls", lo.FlatMap(resp.Candidates, func(c ResponseCandidate, _ int) []string {
+			return lo.Map(c.ToolCalls, func(call ResponseToolCall, _ int) string { return call.Name })
+		})),
+		slog.Int("prompt_tokens", resp.Usage.PromptTokens),
+		slog.Int("completion_tokens", resp.Usage.CompletionTokens),
+		slog.Bool("cached", resp.Cached),
+	)
+}
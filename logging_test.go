@@ -0,0 +1,152 @@
+package llmberjack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func logRecords(buf *bytes.Buffer) []map[string]any {
+	records := []map[string]any{}
+
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+
+		_ = json.Unmarshal([]byte(line), &record)
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tts := [][2]string{
+		{"https://host/v1/models?key=AIzaSecret123&alt=sse", "https://host/v1/models?key=[REDACTED]&alt=sse"},
+		{"Authorization: Bearer abc.def", "Authorization: Bearer [REDACTED]"},
+		{"invalid api key sk-proj-abcdefghijkl", "invalid api key [REDACTED]"},
+		{"key AIzaSyA1234567890abcdefghij", "key [REDACTED]"},
+		{"nothing to see here", "nothing to see here"},
+	}
+
+	for _, tt := range tts {
+		assert.Equal(t, tt[1], RedactSecrets(tt[0]))
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithDefaultProvider(p),
+		WithDefaultModel("themodel"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable ?key=sk-secretsecret")}).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusBadRequest, Err: errors.New("invalid")}).Once()
+
+	_, err := NewUntypedRequest().WithText(RoleUser, "secret prompt").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	_, err = NewUntypedRequest().WithText(RoleUser, "secret prompt").Do(t.Context(), llm)
+
+	assert.NotNil(t, err)
+	assert.NotContains(t, buf.String(), "secret")
+
+	records := logRecords(&buf)
+
+	assert.Len(t, records, 5)
+
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, "themodel", records[0]["model"])
+	assert.Equal(t, "[REDACTED]", records[0]["content"])
+
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, "retrying LLM request", records[1]["msg"])
+	assert.Contains(t, records[1]["error"], "key=[REDACTED]")
+
+	assert.Equal(t, "INFO", records[2]["level"])
+	assert.Equal(t, "received response from LLM provider", records[2]["msg"])
+	assert.Equal(t, DefaultProviderName, records[2]["provider"])
+	assert.Contains(t, records[2], "latency")
+
+	assert.Equal(t, "ERROR", records[4]["level"])
+	assert.Equal(t, "invalid", records[4]["error"])
+	assert.Equal(t, DefaultProviderName, records[4]["provider"])
+	assert.Equal(t, "themodel", records[4]["model"])
+}
+
+func TestLoggingInterruptions(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithDefaultProvider(p),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello, world"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, context.Canceled).Once()
+
+	stream, response := NewUntypedRequest().Stream(t.Context(), llm)
+
+	for range stream {
+		break
+	}
+
+	_, err := response()
+
+	assert.ErrorIs(t, err, ErrStreamStopped)
+
+	_, err = NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, context.Canceled)
+
+	records := lo.Filter(logRecords(&buf), func(record map[string]any, _ int) bool {
+		return record["msg"] == "LLM request failed"
+	})
+
+	assert.Len(t, records, 2)
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, "INFO", records[1]["level"])
+}
+
+func TestLoggingRedactor(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithDefaultProvider(p),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithLogRedactor(func(_ []string, attr slog.Attr) slog.Attr { return attr }),
+	)
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "prompt").Do(t.Context(), llm)
+
+	records := logRecords(&buf)
+
+	assert.Equal(t, []any{"prompt"}, records[0]["content"])
+}
@@ -0,0 +1,110 @@
+package llmberjack
+
+import (
+	"math"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/tidwall/gjson"
+)
+
+// TokenLogprob is the log probability of a token generated in a completion.
+type TokenLogprob struct {
+	Token   string
+	Logprob float64
+	// Alternatives are the most likely tokens at the position of the token,
+	// in descending order of probability. They may include the generated
+	// token.
+	Alternatives []TokenAlternative
+}
+
+// TokenAlternative is a token that could have been generated at a position of
+// a completion, with its log probability.
+type TokenAlternative struct {
+	Token   string
+	Logprob float64
+}
+
+// Probability returns the probability of the token, between 0 and 1.
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
+}
+
+// FieldProbability returns the probability the model assigned to the value it
+// generated for a field of the structured output of the candidate, that is the
+// product of the probabilities of the tokens of the value.
+//
+// The field is selected by a path in the JSON output, such as `label` or
+// `results.0.label`. The request must have been sent with `WithLogprobs()`.
+func (c ResponseCandidate) FieldProbability(path string) (float64, error) {
+	tokens, err := c.fieldTokens(path)
+	if err != nil {
+		return 0, err
+	}
+
+	logprob := 0.0
+
+	for _, token := range tokens {
+		logprob += token.Logprob
+	}
+
+	return math.Exp(logprob), nil
+}
+
+// fieldTokens returns the tokens which generated the value of a field of the
+// structured output of the candidate.
+func (c ResponseCandidate) fieldTokens(path string) ([]TokenLogprob, error) {
+	if len(c.Logprobs) == 0 {
+		return nil, errors.New("candidate has no log probabilities, request them with WithLogprobs()")
+	}
+
+	var text strings.Builder
+
+	for _, token := range c.Logprobs {
+		text.WriteString(token.Token)
+	}
+
+	if text.String() != c.Text {
+		return nil, errors.New("log probabilities do not match the text of the candidate")
+	}
+
+	field := gjson.Get(c.Text, path)
+	if !field.Exists() || field.Index == 0 {
+		return nil, errors.Newf("field '%s' does not exist in candidate", path)
+	}
+
+	start, end := field.Index, field.Index+len(field.Raw)
+
+	// The quotes of strings are not part of the value.
+	if field.Type == gjson.String {
+		start, end = start+1, end-1
+	}
+
+	tokens := make([]TokenLogprob, 0)
+	offset := 0
+
+	for _, token := range c.Logprobs {
+		if offset < end && offset+len(token.Token) > start {
+			tokens = append(tokens, token)
+		}
+
+		offset += len(token.Token)
+	}
+
+	return tokens, nil
+}
+
+// EnumProbability returns the value of an enum field of the structured output
+// of a candidate, along with the probability the model assigned to it.
+//
+// It is typically used to get a confidence score for the label returned by a
+// classifier. See `ResponseCandidate.FieldProbability()` for how the field is
+// selected.
+func EnumProbability[E ~string](candidate *ResponseCandidate, path string) (E, float64, error) {
+	probability, err := candidate.FieldProbability(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return E(gjson.Get(candidate.Text, path).String()), probability, nil
+}
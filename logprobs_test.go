@@ -0,0 +1,68 @@
+package llmberjack
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func logprobsCandidate(tokens ...TokenLogprob) *ResponseCandidate {
+	candidate := ResponseCandidate{Logprobs: tokens}
+
+	for _, token := range tokens {
+		candidate.Text += token.Token
+	}
+
+	return &candidate
+}
+
+func TestFieldProbability(t *testing.T) {
+	type Label string
+
+	candidate := logprobsCandidate(
+		TokenLogprob{Token: `{"`, Logprob: 0},
+		TokenLogprob{Token: `label`, Logprob: 0},
+		TokenLogprob{Token: `":"`, Logprob: 0},
+		TokenLogprob{Token: `fra`, Logprob: math.Log(0.8), Alternatives: []TokenAlternative{{Token: "fra", Logprob: math.Log(0.8)}, {Token: "legit", Logprob: math.Log(0.2)}}},
+		TokenLogprob{Token: `ud`, Logprob: math.Log(0.5)},
+		TokenLogprob{Token: `","`, Logprob: 0},
+		TokenLogprob{Token: `score`, Logprob: 0},
+		TokenLogprob{Token: `":`, Logprob: 0},
+		TokenLogprob{Token: `42`, Logprob: math.Log(0.25)},
+		TokenLogprob{Token: `}`, Logprob: 0},
+	)
+
+	probability, err := candidate.FieldProbability("label")
+
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.4, probability, 1e-9)
+
+	probability, err = candidate.FieldProbability("score")
+
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.25, probability, 1e-9)
+
+	label, probability, err := EnumProbability[Label](candidate, "label")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Label("fraud"), label)
+	assert.InDelta(t, 0.4, probability, 1e-9)
+
+	_, err = candidate.FieldProbability("unknown")
+
+	assert.ErrorContains(t, err, "field 'unknown' does not exist")
+}
+
+func TestFieldProbabilityErrors(t *testing.T) {
+	_, err := (&ResponseCandidate{Text: `{"label":"fraud"}`}).FieldProbability("label")
+
+	assert.ErrorContains(t, err, "candidate has no log probabilities")
+
+	candidate := logprobsCandidate(TokenLogprob{Token: `{"label":"fraud"}`})
+	candidate.Text = `{"label":"legit"}`
+
+	_, err = candidate.FieldProbability("label")
+
+	assert.ErrorContains(t, err, "log probabilities do not match the text of the candidate")
+}
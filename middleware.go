@@ -0,0 +1,90 @@
+package llmberjack
+
+import (
+	"context"
+)
+
+// Invocation describes a single call to a provider, as seen by middlewares.
+type Invocation struct {
+	// ProviderName is the name the provider was registered with.
+	ProviderName string
+	// Provider is the provider the request will be executed on.
+	Provider Llm
+	// Requester is the request to send to the provider.
+	Requester Requester
+
+	// OnDelta is set when the response is streamed, and receives every delta
+	// emitted by the provider.
+	OnDelta func(StreamDelta) bool
+}
+
+// Streaming returns whether the response to this invocation is streamed.
+func (i Invocation) Streaming() bool {
+	return i.OnDelta != nil
+}
+
+// Handler executes an invocation and returns the provider response.
+type Handler func(context.Context, Invocation) (*InnerResponse, error)
+
+// Middleware wraps a Handler to add behavior around every call to a provider.
+//
+// A middleware can inspect or alter the invocation before calling the next
+// handler, inspect the response or error it returned, or short-circuit the
+// chain by not calling it at all.
+//
+// Example usage:
+//
+//	func Logger(next llmberjack.Handler) llmberjack.Handler {
+//		return func(ctx context.Context, inv llmberjack.Invocation) (*llmberjack.InnerResponse, error) {
+//			start := time.Now()
+//			resp, err := next(ctx, inv)
+//
+//			log.Println(inv.ProviderName, time.Since(start), err)
+//
+//			return resp, err
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// buildHandler composes the configured middlewares around the actual call to
+// the provider. The first middleware registered is the outermost one.
+//
+// Retries are handled outside of the middleware chain, so every attempt goes
+// through it, as well as through circuit breaking and rate limiting. Cached
+// responses are served before any of those.
+//
+// A streamed request falling back to a provider that does not support streaming
+// emits the whole text of its response as one delta per candidate.
+func (llm *Llmberjack) buildHandler() Handler {
+	handler := Handler(func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+		llm.recordCall(ctx, inv.Requester.ToRequest().ThreadId)
+		markDispatched(ctx)
+
+		if !inv.Streaming() {
+			return inv.Provider.ChatCompletion(ctx, llm, inv.Requester)
+		}
+
+		if streamer, ok := inv.Provider.(StreamingLlm); ok {
+			return streamer.ChatCompletionStream(ctx, llm, inv.Requester, inv.OnDelta)
+		}
+
+		resp, err := inv.Provider.ChatCompletion(ctx, llm, inv.Requester)
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range resp.Candidates {
+			if !inv.OnDelta(cachedDelta(idx, resp.Candidates[idx])) {
+				return nil, ErrStreamStopped
+			}
+		}
+
+		return resp, nil
+	})
+
+	for i := len(llm.middlewares) - 1; i >= 0; i-- {
+		handler = llm.middlewares[i](handler)
+	}
+
+	return llm.cache(llm.retry(llm.breakCircuit(llm.rateLimit(handler))))
+}
@@ -0,0 +1,109 @@
+package llmberjack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	calls := []string{}
+
+	recorder := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+				calls = append(calls, name+":"+inv.ProviderName)
+
+				resp, err := next(ctx, inv)
+
+				calls = append(calls, name+":done")
+
+				return resp, err
+			}
+		}
+	}
+
+	p1 := NewMockProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithProvider("provider1", p1),
+		WithProvider("provider2", p2),
+		WithMiddleware(recorder("first"), recorder("second")),
+	)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"World"}, nil)
+
+	resp, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	assert.Equal(t, []string{"first:provider1", "second:provider1", "second:done", "first:done"}, calls)
+
+	calls = []string{}
+
+	resp, err = NewUntypedRequest().WithProvider("provider2").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "World", resp.Candidates[0].Text)
+	assert.Equal(t, []string{"first:provider2", "second:provider2", "second:done", "first:done"}, calls)
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	policy := func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			if inv.Requester.ToRequest().Model == nil {
+				return nil, errors.New("a model must be selected")
+			}
+
+			return next(ctx, inv)
+		}
+	}
+
+	llm, _ := New(WithDefaultProvider(p), WithMiddleware(policy))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "a model must be selected")
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = NewUntypedRequest().WithModel("themodel").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestMiddlewareStreaming(t *testing.T) {
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	streamed := false
+
+	llm, _ := New(WithDefaultProvider(p), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			streamed = inv.Streaming()
+
+			return next(ctx, inv)
+		}
+	}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	stream, _ := NewUntypedRequest().Stream(t.Context(), llm)
+
+	for range stream {
+	}
+
+	assert.True(t, streamed)
+}
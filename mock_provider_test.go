@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"reflect"
+	"strings"
 
 	"github.com/checkmarble/llmberjack/internal"
 	"github.com/stretchr/testify/mock"
@@ -84,3 +85,28 @@ func (p *MockProvider) ChatCompletion(ctx context.Context, llm internal.Adapter,
 		},
 	}, nil
 }
+
+// MockStreamingProvider is a MockProvider that streams its response word by
+// word.
+type MockStreamingProvider struct {
+	*MockProvider
+}
+
+func NewMockStreamingProvider() *MockStreamingProvider {
+	return &MockStreamingProvider{NewMockProvider()}
+}
+
+func (p *MockStreamingProvider) ChatCompletionStream(ctx context.Context, llm internal.Adapter, requester Requester, onDelta func(StreamDelta) bool) (*InnerResponse, error) {
+	resp, err := p.ChatCompletion(ctx, llm, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, word := range strings.SplitAfter(resp.Candidates[0].Text, " ") {
+		if !onDelta(StreamDelta{Text: word}) {
+			return nil, ErrStreamStopped
+		}
+	}
+
+	return resp, nil
+}
@@ -1,14 +1,21 @@
 package llmberjack
 
-import "net/http"
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
 
 type llmOption func(*Llmberjack)
 
 // WithDefaultProvider sets what LLM provider to use for communication.
+//
+// The provider is registered under `DefaultProviderName`.
 func WithDefaultProvider(provider Llm) llmOption {
 	return func(llm *Llmberjack) {
-		llm.providers[defaultProvider] = provider
-		llm.defaultProvider = llm.providers[defaultProvider]
+		llm.providers[DefaultProviderName] = provider
+		llm.defaultProvider = llm.providers[DefaultProviderName]
+		llm.defaultProviderName = DefaultProviderName
 	}
 }
 
@@ -22,7 +29,7 @@ func WithProvider(name string, provider Llm) llmOption {
 
 		if llm.defaultProvider == nil {
 			llm.defaultProvider = llm.providers[name]
-			llm.providers[defaultProvider] = llm.providers[name]
+			llm.defaultProviderName = name
 		}
 	}
 }
@@ -45,3 +52,115 @@ func WithHttpClient(client *http.Client) llmOption {
 		llm.httpClient = client
 	}
 }
+
+// WithMiddleware adds middlewares wrapping every call made to the providers.
+//
+// Middlewares are executed in the order they are registered, the first one
+// being the outermost.
+func WithMiddleware(middlewares ...Middleware) llmOption {
+	return func(llm *Llmberjack) {
+		llm.middlewares = append(llm.middlewares, middlewares...)
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry requests failing with
+// transient errors.
+//
+// By default, requests are not retried. The policy can be overridden on each
+// request with `Request.WithRetryPolicy()`.
+func WithRetryPolicy(policy RetryPolicy) llmOption {
+	return func(llm *Llmberjack) {
+		llm.retryPolicy = &policy
+	}
+}
+
+// WithFallbackProviders sets the default providers to try, in order, when the
+// selected provider fails with a retryable error.
+//
+// It can be overridden on each request with `Request.WithFallbackProviders()`.
+func WithFallbackProviders(names ...string) llmOption {
+	return func(llm *Llmberjack) {
+		llm.fallbackProviders = names
+	}
+}
+
+// WithRateLimit enforces client-side rate limiting on the provider registered
+// with the given name. The provider set with `WithDefaultProvider()` is named
+// `DefaultProviderName`.
+//
+// Requests to that provider will block until they fit within the configured
+// limits, or their context is cancelled. Each attempt of a retried request is
+// subject to the rate limit.
+func WithRateLimit(providerName string, limit RateLimit) llmOption {
+	return func(llm *Llmberjack) {
+		llm.rateLimiters[providerName] = newRateLimiter(limit)
+	}
+}
+
+// WithCircuitBreaker enables a circuit breaker on each registered provider.
+//
+// While the circuit of a provider is open, requests to it are immediately
+// rejected with an error matching `ErrProviderUnavailable`, so they can be
+// sent to fallback providers, if configured. The state of each circuit can be
+// retrieved with `CircuitStates()`.
+func WithCircuitBreaker(config CircuitBreaker) llmOption {
+	return func(llm *Llmberjack) {
+		llm.circuitBreaker = &config
+	}
+}
+
+// WithCache enables caching of responses in the provided store.
+//
+// Responses are keyed on the content of the request and the provider it is
+// sent to, and kept for the given TTL (a zero TTL means they do not expire).
+// Requests bound to a thread are never cached, since their history is not part
+// of the key. Caching can be disabled on each request with `Request.NoCache()`.
+func WithCache(store CacheStore, ttl time.Duration) llmOption {
+	return func(llm *Llmberjack) {
+		llm.cacheConfig = &cacheConfig{store: store, ttl: ttl}
+	}
+}
+
+// WithPricing sets the pricing registry used to estimate the cost of requests.
+//
+// Without it, the cost of all requests is reported as unknown.
+func WithPricing(pricing *Pricing) llmOption {
+	return func(llm *Llmberjack) {
+		llm.pricing = pricing
+	}
+}
+
+// WithBudget subjects all requests executed on the adapter to a budget.
+func WithBudget(budget *Budget) llmOption {
+	return func(llm *Llmberjack) {
+		llm.budget = budget
+	}
+}
+
+// WithTracing enables OpenTelemetry instrumentation of requests.
+//
+// Each execution of a request opens a span, with attributes following the
+// semantic conventions for generative AI, child spans for the tools executed
+// for it, and events for retries and fallbacks.
+func WithTracing(config Tracing) llmOption {
+	return func(llm *Llmberjack) {
+		llm.tracing = &config
+	}
+}
+
+// WithLogger enables logging of the lifecycle of requests.
+//
+// Attributes are passed through a redactor before being logged, which masks
+// the content of messages and API keys by default. See `WithLogRedactor()`.
+func WithLogger(logger *slog.Logger) llmOption {
+	return func(llm *Llmberjack) {
+		llm.logger = logger
+	}
+}
+
+// WithLogRedactor replaces the default redactor applied to log attributes.
+func WithLogRedactor(redactor Redactor) llmOption {
+	return func(llm *Llmberjack) {
+		llm.redactor = redactor
+	}
+}
@@ -0,0 +1,240 @@
+package llmberjack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// AnyProvider can be used as the provider name of a pricing to apply it to
+// models regardless of the provider they are executed on.
+const AnyProvider = "*"
+
+// ModelPricing defines the rates of a model, per million tokens.
+type ModelPricing struct {
+	// Input is the rate of prompt tokens.
+	Input float64 `json:"input"`
+	// Output is the rate of completion tokens, including reasoning tokens.
+	Output float64 `json:"output"`
+	// CachedInput is the rate of prompt tokens served from the provider's
+	// cache. If zero, they are priced at the input rate.
+	CachedInput float64 `json:"cached_input,omitempty"`
+}
+
+// Pricing is a registry of model rates, used to estimate the cost of requests.
+//
+// Models are matched on the longest configured prefix of the model name
+// reported by the provider, so that a pricing for `gpt-4o` applies to
+// `gpt-4o-2024-08-06`. Pricings registered for a specific provider name take
+// precedence over the ones registered for `AnyProvider`.
+//
+// Requests are priced under the name their provider was registered under, then
+// under the system name of the provider, if it implements `SystemNamer`
+// (`openai`, `gcp.gemini`, etc.), so pricings also apply to providers set with
+// `WithDefaultProvider()`.
+type Pricing struct {
+	mtx    sync.RWMutex
+	models map[string]map[string]ModelPricing
+}
+
+// NewPricing creates an empty pricing registry.
+func NewPricing() *Pricing {
+	return &Pricing{
+		models: make(map[string]map[string]ModelPricing),
+	}
+}
+
+// LoadPricing reads a pricing registry from JSON, keyed by provider name, then
+// model name:
+//
+//	{
+//		"openai": {
+//			"gpt-4o": { "input": 2.5, "output": 10, "cached_input": 1.25 }
+//		},
+//		"*": {
+//			"gemini-2.5-flash": { "input": 0.3, "output": 2.5 }
+//		}
+//	}
+func LoadPricing(r io.Reader) (*Pricing, error) {
+	p := NewPricing()
+
+	if err := json.NewDecoder(r).Decode(&p.models); err != nil {
+		return nil, errors.Wrap(err, "could not decode pricing")
+	}
+
+	return p, nil
+}
+
+// LoadPricingFile reads a pricing registry from a JSON file. See `LoadPricing`
+// for the expected format.
+func LoadPricingFile(path string) (*Pricing, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open pricing file")
+	}
+
+	defer f.Close()
+
+	return LoadPricing(f)
+}
+
+// Set registers the rates of a model on a provider.
+func (p *Pricing) Set(providerName, model string, pricing ModelPricing) *Pricing {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if _, ok := p.models[providerName]; !ok {
+		p.models[providerName] = make(map[string]ModelPricing)
+	}
+
+	p.models[providerName][model] = pricing
+
+	return p
+}
+
+// Lookup retrieves the rates of a model on a provider.
+func (p *Pricing) Lookup(providerName, model string) (ModelPricing, bool) {
+	return p.lookup(model, providerName)
+}
+
+// lookup retrieves the rates of a model under the first provider name it is
+// registered for, then under `AnyProvider`.
+func (p *Pricing) lookup(model string, providerNames ...string) (ModelPricing, bool) {
+	if model == "" {
+		return ModelPricing{}, false
+	}
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, name := range append(providerNames, AnyProvider) {
+		var (
+			match   ModelPricing
+			matched = -1
+		)
+
+		for prefix, pricing := range p.models[name] {
+			if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+				match, matched = pricing, len(prefix)
+			}
+		}
+
+		if matched >= 0 {
+			return match, true
+		}
+	}
+
+	return ModelPricing{}, false
+}
+
+// Cost estimates the cost of a request from its usage.
+func (p *Pricing) Cost(providerName, model string, usage Usage) Cost {
+	return p.cost(model, usage, providerName)
+}
+
+// cost estimates the cost of a request from its usage, with the rates of the
+// first provider name the model is registered for.
+func (p *Pricing) cost(model string, usage Usage, providerNames ...string) Cost {
+	pricing, ok := p.lookup(model, providerNames...)
+	if !ok {
+		return Cost{Unknown: true}
+	}
+
+	cachedRate := pricing.CachedInput
+	if cachedRate == 0 {
+		cachedRate = pricing.Input
+	}
+
+	amount := float64(usage.PromptTokens-usage.CachedTokens)*pricing.Input +
+		float64(usage.CachedTokens)*cachedRate +
+		float64(usage.CompletionTokens)*pricing.Output
+
+	return Cost{Amount: amount / 1_000_000}
+}
+
+// Cost is the estimated cost of one or several requests, in the currency used
+// in the pricing registry.
+type Cost struct {
+	Amount float64
+	// Unknown is set when the cost of at least one request could not be
+	// estimated, because its model was not found in the pricing registry. In
+	// that case, Amount is only a lower bound.
+	Unknown bool
+}
+
+// Add returns the sum of two costs.
+func (c Cost) Add(other Cost) Cost {
+	return Cost{
+		Amount:  c.Amount + other.Amount,
+		Unknown: c.Unknown || other.Unknown,
+	}
+}
+
+func (c Cost) String() string {
+	if c.Unknown {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%.6f", c.Amount)
+}
+
+// costTracker accumulates the cost of requests.
+type costTracker struct {
+	mtx  sync.Mutex
+	cost Cost
+}
+
+func (t *costTracker) add(cost Cost) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.cost = t.cost.Add(cost)
+}
+
+func (t *costTracker) total() Cost {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.cost
+}
+
+// recordCost estimates the cost of a response, and adds it to the totals of the
+// adapter and of the thread it belongs to.
+//
+// Responses served from the cache did not cost anything.
+func (llm *Llmberjack) recordCost(providerName string, provider Llm, threadId *ThreadId, resp *InnerResponse) Cost {
+	var cost Cost
+
+	switch {
+	case resp.Cached:
+	case llm.pricing == nil:
+		cost = Cost{Unknown: true}
+	default:
+		providerNames := []string{providerName}
+
+		if namer, ok := provider.(SystemNamer); ok {
+			providerNames = append(providerNames, namer.SystemName())
+		}
+
+		cost = llm.pricing.cost(resp.Model, resp.Usage, providerNames...)
+	}
+
+	llm.cost.add(cost)
+
+	if threadId != nil {
+		threadId.cost.add(cost)
+	}
+
+	return cost
+}
+
+// TotalCost returns the accumulated cost of all requests executed on the
+// adapter.
+func (llm *Llmberjack) TotalCost() Cost {
+	return llm.cost.total()
+}
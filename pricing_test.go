@@ -0,0 +1,171 @@
+package llmberjack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPricingLookup(t *testing.T) {
+	pricing, err := LoadPricing(strings.NewReader(`{
+		"openai": {
+			"gpt-4o": { "input": 2.5, "output": 10, "cached_input": 1.25 },
+			"gpt-4o-mini": { "input": 0.15, "output": 0.6 }
+		},
+		"*": {
+			"gpt-4o": { "input": 5, "output": 20 },
+			"gemini": { "input": 0.3, "output": 2.5 }
+		}
+	}`))
+
+	assert.Nil(t, err)
+
+	tts := []struct {
+		provider, model string
+		pricing         ModelPricing
+		ok              bool
+	}{
+		{"openai", "gpt-4o", ModelPricing{2.5, 10, 1.25}, true},
+		{"openai", "gpt-4o-2024-08-06", ModelPricing{2.5, 10, 1.25}, true},
+		{"openai", "gpt-4o-mini-2024-07-18", ModelPricing{0.15, 0.6, 0}, true},
+		{"azure", "gpt-4o", ModelPricing{5, 20, 0}, true},
+		{"vertex", "gemini-2.5-flash", ModelPricing{0.3, 2.5, 0}, true},
+		{"openai", "o3", ModelPricing{}, false},
+		{"openai", "", ModelPricing{}, false},
+	}
+
+	for _, tt := range tts {
+		pricing, ok := pricing.Lookup(tt.provider, tt.model)
+
+		assert.Equal(t, tt.ok, ok, tt.model)
+		assert.Equal(t, tt.pricing, pricing, tt.model)
+	}
+
+	_, err = LoadPricing(strings.NewReader(`{"openai": []}`))
+
+	assert.ErrorContains(t, err, "could not decode pricing")
+}
+
+func TestPricingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.json")
+
+	_ = os.WriteFile(path, []byte(`{"*": {"themodel": {"input": 1, "output": 2}}}`), 0o644)
+
+	pricing, err := LoadPricingFile(path)
+
+	assert.Nil(t, err)
+
+	_, ok := pricing.Lookup("openai", "themodel")
+
+	assert.True(t, ok)
+
+	_, err = LoadPricingFile(filepath.Join(t.TempDir(), "missing.json"))
+
+	assert.ErrorContains(t, err, "could not open pricing file")
+}
+
+func TestPricingCost(t *testing.T) {
+	pricing := NewPricing().
+		Set("openai", "gpt-4o", ModelPricing{Input: 2.5, Output: 10, CachedInput: 1.25}).
+		Set("openai", "gpt-4o-mini", ModelPricing{Input: 1, Output: 2})
+
+	cost := pricing.Cost("openai", "gpt-4o", Usage{PromptTokens: 1_000_000, CachedTokens: 400_000, CompletionTokens: 100_000})
+
+	assert.False(t, cost.Unknown)
+	assert.InDelta(t, 0.6*2.5+0.4*1.25+0.1*10, cost.Amount, 1e-9)
+	assert.Equal(t, "3.000000", cost.String())
+
+	// Cached tokens default to the input rate.
+	cost = pricing.Cost("openai", "gpt-4o-mini", Usage{PromptTokens: 1_000_000, CachedTokens: 500_000})
+
+	assert.InDelta(t, 1, cost.Amount, 1e-9)
+
+	cost = pricing.Cost("openai", "o3", Usage{PromptTokens: 1_000_000})
+
+	assert.True(t, cost.Unknown)
+	assert.Equal(t, "unknown", cost.String())
+	assert.Equal(t, "unknown", Cost{Amount: 1}.Add(cost).String())
+}
+
+func TestRequestCostDefaultProvider(t *testing.T) {
+	p := namedMockProvider{NewMockProvider()}
+	p.On("Init", mock.Anything).Return(nil)
+
+	withUsage := func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			resp, err := next(ctx, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Model = "themodel"
+			resp.Usage = Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+
+			return resp, nil
+		}
+	}
+
+	pricing := NewPricing().
+		Set("thesystem", "themodel", ModelPricing{Input: 1, Output: 2}).
+		Set("othersystem", "themodel", ModelPricing{Input: 10, Output: 20}).
+		Set(AnyProvider, "themodel", ModelPricing{Input: 100, Output: 200})
+
+	llm, _ := New(WithDefaultProvider(p), WithMiddleware(withUsage), WithPricing(pricing))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	resp, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Cost{Amount: 3}, resp.Cost)
+}
+
+func TestRequestCost(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	withUsage := func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			resp, err := next(ctx, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Model = *inv.Requester.ToRequest().Model
+			resp.Usage = Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+
+			return resp, nil
+		}
+	}
+
+	llm, _ := New(
+		WithDefaultProvider(p),
+		WithMiddleware(withUsage),
+		WithPricing(NewPricing().Set(AnyProvider, "themodel", ModelPricing{Input: 1, Output: 2})),
+	)
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	resp, err := NewUntypedRequest().WithModel("themodel").CreateThread().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Cost{Amount: 3}, resp.Cost)
+
+	threadId := resp.ThreadId
+
+	resp, _ = NewUntypedRequest().WithModel("themodel").InThread(threadId).Do(t.Context(), llm)
+
+	assert.Equal(t, Cost{Amount: 3}, resp.Cost)
+	assert.Equal(t, Cost{Amount: 6}, threadId.Cost())
+
+	resp, _ = NewUntypedRequest().WithModel("othermodel").Do(t.Context(), llm)
+
+	assert.Equal(t, "unknown", resp.Cost.String())
+	assert.Equal(t, Cost{Amount: 6, Unknown: true}, llm.TotalCost())
+	assert.Equal(t, Cost{Amount: 6}, threadId.Cost())
+}
@@ -0,0 +1,219 @@
+package llmberjack
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// PromptSettings are the request settings declared in the front-matter of a
+// prompt file. Settings that are not declared are left to the defaults of the
+// adapter.
+type PromptSettings struct {
+	Provider          string   `yaml:"provider" toml:"provider"`
+	Model             string   `yaml:"model" toml:"model"`
+	Temperature       *float64 `yaml:"temperature" toml:"temperature"`
+	MaxTokens         *int     `yaml:"max_tokens" toml:"max_tokens"`
+	Thinking          *bool    `yaml:"thinking" toml:"thinking"`
+	SchemaName        string   `yaml:"schema_name" toml:"schema_name"`
+	SchemaDescription string   `yaml:"schema_description" toml:"schema_description"`
+}
+
+// Prompt is a system prompt loaded from a file, along with the settings of the
+// requests it should be sent with.
+type Prompt struct {
+	// Name identifies the prompt in its registry: its path, without extension.
+	Name     string
+	Settings PromptSettings
+	// Body is the content of the prompt file, after the front-matter.
+	Body string
+}
+
+// PromptRegistry is a collection of prompts loaded from Markdown files.
+type PromptRegistry struct {
+	prompts map[string]Prompt
+}
+
+// LoadPrompts loads all Markdown (`.md`) files from a filesystem, such as an
+// `embed.FS`, into a prompt registry.
+//
+// Each prompt is named after its path in the filesystem, without extension, so
+// that `screening/summary.md` is named `screening/summary`. A prompt file can
+// start with a front-matter declaring its settings, either in YAML, delimited
+// by `---` lines, or in TOML, delimited by `+++` lines:
+//
+//	---
+//	provider: gemini
+//	model: gemini-2.5-flash
+//	temperature: 0.2
+//	max_tokens: 1024
+//	thinking: false
+//	schema_name: summary
+//	---
+//
+//	You are a compliance analyst...
+func LoadPrompts(fsys fs.FS) (*PromptRegistry, error) {
+	registry := PromptRegistry{
+		prompts: make(map[string]Prompt),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(filePath) != ".md" {
+			return nil
+		}
+
+		buf, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return errors.Wrapf(err, "could not read prompt '%s'", filePath)
+		}
+
+		prompt, err := parsePrompt(strings.TrimSuffix(filePath, ".md"), buf)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse prompt '%s'", filePath)
+		}
+
+		registry.prompts[prompt.Name] = prompt
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &registry, nil
+}
+
+// Get retrieves a prompt by its name.
+func (r *PromptRegistry) Get(name string) (Prompt, bool) {
+	prompt, ok := r.prompts[name]
+
+	return prompt, ok
+}
+
+// Names returns the names of all prompts in the registry, sorted.
+func (r *PromptRegistry) Names() []string {
+	names := lo.Keys(r.prompts)
+	slices.Sort(names)
+
+	return names
+}
+
+// NewRequestFromPrompt creates a request configured from a prompt of the
+// registry: the body of the prompt is added as a system prompt, and the
+// settings declared in its front-matter are applied.
+//
+// The request can be further configured, including overriding the settings of
+// the prompt, as any other request.
+func NewRequestFromPrompt[T any](registry *PromptRegistry, name string) Request[T] {
+	r := NewRequest[T]()
+
+	prompt, ok := registry.Get(name)
+	if !ok {
+		r.err = errors.CombineErrors(r.err, errors.Newf("prompt '%s' does not exist", name))
+		return r
+	}
+
+	settings := prompt.Settings
+
+	if settings.Provider != "" {
+		r = r.WithProvider(settings.Provider)
+	}
+	if settings.Model != "" {
+		r = r.WithModel(settings.Model)
+	}
+	if settings.Temperature != nil {
+		r = r.WithTemperature(*settings.Temperature)
+	}
+	if settings.MaxTokens != nil {
+		r = r.WithMaxTokens(*settings.MaxTokens)
+	}
+	if settings.Thinking != nil {
+		r = r.WithThinking(*settings.Thinking)
+	}
+	if settings.SchemaName != "" || settings.SchemaDescription != "" {
+		r = r.WithSchemaDescription(settings.SchemaName, settings.SchemaDescription)
+	}
+
+	return r.WithInstruction(prompt.Body)
+}
+
+// parsePrompt splits the front-matter of a prompt file from its body.
+func parsePrompt(name string, buf []byte) (Prompt, error) {
+	prompt := Prompt{Name: name}
+
+	var (
+		delimiter string
+		unmarshal func([]byte, any) error
+	)
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("---\n")), bytes.HasPrefix(buf, []byte("---\r\n")):
+		delimiter, unmarshal = "---", unmarshalYaml
+	case bytes.HasPrefix(buf, []byte("+++\n")), bytes.HasPrefix(buf, []byte("+++\r\n")):
+		delimiter, unmarshal = "+++", unmarshalToml
+	default:
+		prompt.Body = strings.TrimSpace(string(buf))
+
+		return prompt, nil
+	}
+
+	lines := strings.SplitAfter(string(buf), "\n")
+
+	for idx, line := range lines[1:] {
+		if strings.TrimSpace(line) != delimiter {
+			continue
+		}
+
+		frontMatter := strings.Join(lines[1:idx+1], "")
+
+		if err := unmarshal([]byte(frontMatter), &prompt.Settings); err != nil {
+			return Prompt{}, errors.Wrap(err, "invalid front-matter")
+		}
+
+		prompt.Body = strings.TrimSpace(strings.Join(lines[idx+2:], ""))
+
+		return prompt, nil
+	}
+
+	return Prompt{}, errors.New("front-matter is not terminated")
+}
+
+// unmarshalYaml decodes a YAML front-matter, rejecting unknown keys.
+func unmarshalYaml(buf []byte, settings any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.KnownFields(true)
+
+	// An empty front-matter has no document to decode.
+	if err := decoder.Decode(settings); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// unmarshalToml decodes a TOML front-matter, rejecting unknown keys.
+func unmarshalToml(buf []byte, settings any) error {
+	meta, err := toml.Decode(string(buf), settings)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return errors.Newf("unknown keys: %s", strings.Join(lo.Map(undecoded, func(key toml.Key, _ int) string {
+			return key.String()
+		}), ", "))
+	}
+
+	return nil
+}
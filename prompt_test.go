@@ -0,0 +1,107 @@
+package llmberjack
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPrompts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"screening/summary.md": {Data: []byte("---\nprovider: gemini\nmodel: gemini-2.5-flash\ntemperature: 0.2\nmax_tokens: 1024\nthinking: false\nschema_name: summary\n---\n\nSummarize the alert.\n")},
+		"screening/triage.md":  {Data: []byte("+++\nmodel = \"gpt-4o\"\nschema_name = \"triage\"\nschema_description = \"Triage decision\"\n+++\nTriage the alert.\n")},
+		"plain.md":             {Data: []byte("Just a prompt.\n")},
+		"README.txt":           {Data: []byte("Not a prompt.")},
+	}
+
+	registry, err := LoadPrompts(fsys)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"plain", "screening/summary", "screening/triage"}, registry.Names())
+
+	prompt, ok := registry.Get("screening/summary")
+
+	assert.True(t, ok)
+	assert.Equal(t, "Summarize the alert.", prompt.Body)
+	assert.Equal(t, PromptSettings{
+		Provider:    "gemini",
+		Model:       "gemini-2.5-flash",
+		Temperature: lo.ToPtr(0.2),
+		MaxTokens:   lo.ToPtr(1024),
+		Thinking:    lo.ToPtr(false),
+		SchemaName:  "summary",
+	}, prompt.Settings)
+
+	prompt, ok = registry.Get("screening/triage")
+
+	assert.True(t, ok)
+	assert.Equal(t, "Triage the alert.", prompt.Body)
+	assert.Equal(t, PromptSettings{Model: "gpt-4o", SchemaName: "triage", SchemaDescription: "Triage decision"}, prompt.Settings)
+
+	prompt, ok = registry.Get("plain")
+
+	assert.True(t, ok)
+	assert.Equal(t, "Just a prompt.", prompt.Body)
+	assert.Zero(t, prompt.Settings)
+
+	_, ok = registry.Get("README")
+
+	assert.False(t, ok)
+}
+
+func TestLoadInvalidPrompts(t *testing.T) {
+	_, err := LoadPrompts(fstest.MapFS{
+		"unterminated.md": {Data: []byte("---\nmodel: gpt-4o\n")},
+	})
+
+	assert.ErrorContains(t, err, "could not parse prompt 'unterminated.md': front-matter is not terminated")
+
+	_, err = LoadPrompts(fstest.MapFS{
+		"invalid.md": {Data: []byte("---\ntemperature: hot\n---\n")},
+	})
+
+	assert.ErrorContains(t, err, "invalid front-matter")
+
+	// Unknown keys are rejected, so that typos are not silently ignored.
+	_, err = LoadPrompts(fstest.MapFS{
+		"unknown.md": {Data: []byte("---\ntemprature: 0.5\n---\n")},
+	})
+
+	assert.ErrorContains(t, err, "invalid front-matter")
+	assert.ErrorContains(t, err, "temprature")
+
+	_, err = LoadPrompts(fstest.MapFS{
+		"unknown.md": {Data: []byte("+++\nmodel = \"gpt-4o\"\nmax_token = 100\n+++\n")},
+	})
+
+	assert.ErrorContains(t, err, "invalid front-matter: unknown keys: max_token")
+}
+
+func TestNewRequestFromPrompt(t *testing.T) {
+	type Summary struct {
+		Text string `json:"text"`
+	}
+
+	registry, _ := LoadPrompts(fstest.MapFS{
+		"screening/summary.md": {Data: []byte("---\nprovider: gemini\nmodel: gemini-2.5-flash\ntemperature: 0.2\nmax_tokens: 1024\nthinking: true\nschema_name: summary\n---\nSummarize the alert.")},
+	})
+
+	req := NewRequestFromPrompt[Summary](registry, "screening/summary")
+
+	assert.Nil(t, req.err)
+	assert.Equal(t, "gemini", lo.FromPtr(req.provider))
+	assert.Equal(t, "gemini-2.5-flash", lo.FromPtr(req.Model))
+	assert.Equal(t, 0.2, lo.FromPtr(req.Temperature))
+	assert.Equal(t, 1024, lo.FromPtr(req.MaxTokens))
+	assert.True(t, lo.FromPtr(req.Thinking))
+	assert.Equal(t, "summary", req.SchemaName)
+	assert.Len(t, req.Messages, 1)
+	assert.Equal(t, RoleSystem, req.Messages[0].Role)
+	assertParts(t, req.Messages[0].Parts, "Summarize the alert.")
+
+	req = NewRequestFromPrompt[Summary](registry, "screening/unknown")
+
+	assert.ErrorContains(t, req.err, "prompt 'screening/unknown' does not exist")
+}
@@ -0,0 +1,269 @@
+package llmberjack
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// RateLimit configures client-side rate limiting for a provider.
+//
+// Requests exceeding the limits block until enough capacity is available, or
+// their context is cancelled. Both limits are enforced with a token bucket
+// allowing bursts up to the per-minute limit.
+type RateLimit struct {
+	// RequestsPerMinute is the maximum number of requests sent to the
+	// provider per minute. Zero means unlimited.
+	RequestsPerMinute int
+	// TokensPerMinute is the maximum number of tokens consumed per minute.
+	// Zero means unlimited.
+	TokensPerMinute int
+
+	// EstimateTokens estimates how many tokens a request will consume, before
+	// it is sent. Once the response is received, the estimation is reconciled
+	// with the actual usage reported by the provider. If not provided, the
+	// tokens are counted by the provider if it implements `TokenCounter`, or
+	// estimated from the size of the messages and of the thread history, and
+	// the maximum number of tokens requested.
+	EstimateTokens func(Requester) int
+}
+
+// bucket is a token bucket refilling continuously up to its capacity.
+type bucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	return &bucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / time.Minute.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long to wait until n tokens are available.
+func (b *bucket) wait(n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+
+	return time.Duration(math.Ceil((n - b.tokens) / b.rate * float64(time.Second)))
+}
+
+type rateLimiter struct {
+	mtx      sync.Mutex
+	limit    RateLimit
+	requests *bucket
+	tokens   *bucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:    limit,
+		requests: newBucket(limit.RequestsPerMinute),
+		tokens:   newBucket(limit.TokensPerMinute),
+	}
+}
+
+// acquire blocks until a request consuming the given number of tokens can be
+// sent.
+func (l *rateLimiter) acquire(ctx context.Context, tokens int) error {
+	for {
+		l.mtx.Lock()
+
+		now := time.Now()
+		delay := time.Duration(0)
+
+		if l.requests != nil {
+			l.requests.refill(now)
+			delay = max(delay, l.requests.wait(1))
+		}
+		if l.tokens != nil {
+			l.tokens.refill(now)
+			// A request larger than the bucket could never be sent, so it only
+			// waits for the bucket to be full.
+			delay = max(delay, l.tokens.wait(min(float64(tokens), l.tokens.capacity)))
+		}
+
+		if delay == 0 {
+			if l.requests != nil {
+				l.requests.tokens -= 1
+			}
+			if l.tokens != nil {
+				l.tokens.tokens -= float64(tokens)
+			}
+
+			l.mtx.Unlock()
+
+			return nil
+		}
+
+		l.mtx.Unlock()
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reconcile corrects the token bucket once the actual consumption of a request
+// is known.
+func (l *rateLimiter) reconcile(estimated, actual int) {
+	if l.tokens == nil {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.tokens.tokens = min(l.tokens.capacity, l.tokens.tokens+float64(estimated-actual))
+}
+
+// estimate returns how many tokens an invocation is expected to consume.
+//
+// Tokens are only counted by the provider when all message parts can be
+// rewound, since counting them reads them.
+func (l *rateLimiter) estimate(ctx context.Context, llm *Llmberjack, inv Invocation) int {
+	if l.limit.EstimateTokens != nil {
+		return l.limit.EstimateTokens(inv.Requester)
+	}
+
+	r := inv.Requester.ToRequest()
+
+	if counter, ok := supports[TokenCounter](inv.Provider); ok && seekableParts(r) {
+		if count, err := counter.CountTokens(ctx, llm, inv.Requester); err == nil {
+			return count.Tokens + lo.FromPtr(r.MaxTokens)
+		}
+	}
+
+	tokens := estimateTokens(r)
+
+	if compactor, ok := supports[HistoryCompactor](inv.Provider); ok && r.ThreadId != nil {
+		for _, msg := range compactor.ThreadHistory(r.ThreadId) {
+			tokens += msg.Tokens
+		}
+	}
+
+	return tokens
+}
+
+// dispatchContextKey carries a flag set once an invocation was dispatched to its
+// provider.
+type dispatchContextKey struct{}
+
+// markDispatched records that the invocation of a context is dispatched to its
+// provider, and that the tokens it acquired from rate limits are consumed.
+func markDispatched(ctx context.Context) {
+	if dispatched, ok := ctx.Value(dispatchContextKey{}).(*atomic.Bool); ok {
+		dispatched.Store(true)
+	}
+}
+
+// rateLimit wraps a handler to enforce the rate limit configured for the
+// provider of the invocation, if any.
+func (llm *Llmberjack) rateLimit(next Handler) Handler {
+	return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+		limiter, ok := llm.rateLimiters[inv.ProviderName]
+		if !ok {
+			return next(ctx, inv)
+		}
+
+		estimated := limiter.estimate(ctx, llm, inv)
+
+		if err := limiter.acquire(ctx, estimated); err != nil {
+			return nil, err
+		}
+
+		dispatched := &atomic.Bool{}
+
+		resp, err := next(context.WithValue(ctx, dispatchContextKey{}, dispatched), inv)
+
+		switch {
+		// Tokens are only given back when the request failed before being sent,
+		// since the provider may have consumed them otherwise.
+		case err != nil:
+			if !dispatched.Load() {
+				limiter.reconcile(estimated, 0)
+			}
+		case resp.Usage.TotalTokens > 0:
+			limiter.reconcile(estimated, resp.Usage.TotalTokens)
+		}
+
+		return resp, err
+	}
+}
+
+// estimateTokens roughly estimates how many tokens a request will consume,
+// assuming four bytes per token for the messages, plus the maximum number of
+// tokens to generate.
+//
+// Only the size of seekable message parts, or of parts reporting their length,
+// can be known without consuming them, other parts are ignored.
+func estimateTokens(r innerRequest) int {
+	size := int64(0)
+
+	for _, msg := range r.Messages {
+		for _, part := range msg.Parts {
+			seeker, ok := part.(io.Seeker)
+			if !ok {
+				if sized, ok := part.(interface{ Len() int }); ok {
+					size += int64(sized.Len())
+				}
+
+				continue
+			}
+
+			if n, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				size += n
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				continue
+			}
+		}
+	}
+
+	tokens := int(size / 4)
+
+	if r.MaxTokens != nil {
+		tokens += *r.MaxTokens
+	}
+
+	return tokens
+}
+
+// seekableParts returns whether all message parts of a request can be rewound
+// after being read.
+func seekableParts(r innerRequest) bool {
+	for _, msg := range r.Messages {
+		for _, part := range msg.Parts {
+			if _, ok := part.(io.ReadSeeker); !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
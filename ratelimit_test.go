@@ -0,0 +1,203 @@
+package llmberjack
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimiterRequests(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{RequestsPerMinute: 60})
+
+	for range 60 {
+		assert.Nil(t, limiter.acquire(t.Context(), 0))
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, limiter.acquire(ctx, 0), context.DeadlineExceeded)
+}
+
+func TestRateLimiterTokens(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{TokensPerMinute: 6000})
+
+	assert.Nil(t, limiter.acquire(t.Context(), 6000))
+
+	start := time.Now()
+
+	assert.Nil(t, limiter.acquire(t.Context(), 10))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	// Actual consumption was lower than the estimation, tokens are given back.
+	limiter.reconcile(6000, 1000)
+
+	start = time.Now()
+
+	assert.Nil(t, limiter.acquire(t.Context(), 4000))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestRateLimiterLargeRequest(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{TokensPerMinute: 6000})
+
+	// A request larger than the limit is sent when the bucket is full.
+	assert.Nil(t, limiter.acquire(t.Context(), 10000))
+}
+
+func TestRateLimitProvider(t *testing.T) {
+	p1 := NewMockProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, err := New(
+		WithProvider("p1", p1),
+		WithProvider("p2", p2),
+		WithRateLimit("p1", RateLimit{RequestsPerMinute: 1}),
+	)
+
+	assert.Nil(t, err)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err = NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = NewUntypedRequest().Do(ctx, llm)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	p1.AssertNumberOfCalls(t, "ChatCompletion", 1)
+
+	for range 5 {
+		_, err = NewUntypedRequest().WithProvider("p2").Do(ctx, llm)
+
+		assert.Nil(t, err)
+	}
+
+	p3 := NewMockProvider()
+	p3.On("Init", mock.Anything).Return(nil)
+
+	llm, err = New(WithDefaultProvider(p3), WithRateLimit(DefaultProviderName, RateLimit{RequestsPerMinute: 1}))
+
+	assert.Nil(t, err)
+
+	p3.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err = NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	_, err = NewUntypedRequest().Do(ctx, llm)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	p3.AssertNumberOfCalls(t, "ChatCompletion", 1)
+
+	_, err = New(WithProvider("p1", p1), WithRateLimit("unknown", RateLimit{}))
+
+	assert.ErrorContains(t, err, "rate limit configured for unknown provider 'unknown'")
+}
+
+func TestEstimateTokens(t *testing.T) {
+	req := NewUntypedRequest().
+		WithInstruction(strings.Repeat("a", 400)).
+		WithText(RoleUser, strings.Repeat("b", 200)).
+		WithMaxTokens(50)
+
+	assert.Equal(t, 200, estimateTokens(req.innerRequest))
+
+	// Parts are left readable.
+	assertParts(t, req.Messages[1].Parts, strings.Repeat("b", 200))
+
+	req.MaxTokens = lo.ToPtr(0)
+
+	assert.Equal(t, 150, estimateTokens(req.innerRequest))
+
+	// Parts which cannot be rewound are counted if they report their length.
+	req = req.WithTextReader(RoleUser, bytes.NewBufferString(strings.Repeat("c", 40)))
+
+	assert.Equal(t, 160, estimateTokens(req.innerRequest))
+}
+
+type historyMockProvider struct {
+	*MockProvider
+}
+
+func (p historyMockProvider) ThreadHistory(*ThreadId) []HistoryMessage {
+	return []HistoryMessage{{Role: RoleUser, Tokens: 30}, {Role: RoleAi, Tokens: 70}}
+}
+
+func (p historyMockProvider) CompactThread(*ThreadId, int, string) {}
+
+func TestRateLimiterEstimate(t *testing.T) {
+	llm, _ := New()
+	limiter := newRateLimiter(RateLimit{TokensPerMinute: 1000})
+
+	req := NewUntypedRequest().WithText(RoleUser, strings.Repeat("a", 400)).WithMaxTokens(50)
+
+	// Providers counting tokens are preferred.
+	inv := Invocation{Provider: mockTokenCounter{NewMockProvider()}, Requester: req}
+
+	assert.Equal(t, 60, limiter.estimate(t.Context(), llm, inv))
+
+	// Unless counting would consume message parts.
+	inv.Requester = req.WithTextReader(RoleUser, iotest.OneByteReader(strings.NewReader("b")))
+
+	assert.Equal(t, 150, limiter.estimate(t.Context(), llm, inv))
+
+	// The history of the thread is estimated.
+	inv = Invocation{Provider: historyMockProvider{NewMockProvider()}, Requester: req.InThread(&ThreadId{})}
+
+	assert.Equal(t, 250, limiter.estimate(t.Context(), llm, inv))
+}
+
+func TestRateLimitRefund(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	failing := func(next Handler) Handler {
+		return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+			return nil, errors.New("middleware error")
+		}
+	}
+
+	for _, tt := range []struct {
+		middlewares []Middleware
+		refunded    bool
+	}{
+		{nil, false},
+		{[]Middleware{failing}, true},
+	} {
+		llm, _ := New(
+			WithProvider("p1", p),
+			WithRateLimit("p1", RateLimit{TokensPerMinute: 1000}),
+			WithMiddleware(tt.middlewares...),
+		)
+
+		p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, errors.New("provider error"))
+
+		_, err := NewUntypedRequest().WithText(RoleUser, strings.Repeat("a", 400)).Do(t.Context(), llm)
+
+		assert.Error(t, err)
+
+		// Tokens are only refunded if the request failed before being sent.
+		if tt.refunded {
+			assert.InDelta(t, 1000, llm.rateLimiters["p1"].tokens.tokens, 1)
+		} else {
+			assert.InDelta(t, 900, llm.rateLimiters["p1"].tokens.tokens, 1)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package llmberjack
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/cockroachdb/errors"
+)
+
+// ErrNotRecorded is returned by a `Recorder` replaying a cassette when no
+// recorded interaction matches a request.
+var ErrNotRecorded = errors.New("no recorded interaction matches the request")
+
+// RecordMode determines how a `Recorder` uses its cassette.
+type RecordMode int
+
+const (
+	// RecordModeReplay only serves responses from the cassette, and fails with
+	// `ErrNotRecorded` on unknown requests. The wrapped provider is never
+	// called.
+	RecordModeReplay RecordMode = iota
+	// RecordModeRecord sends every request to the wrapped provider and records
+	// it, replacing the previous content of the cassette.
+	RecordModeRecord
+	// RecordModeAuto replays known requests, and records unknown ones.
+	//
+	// Since replayed interactions are not saved to the history of the wrapped
+	// provider, an unknown request is refused in a thread whose previous
+	// requests were replayed, as it would be sent without their context.
+	RecordModeAuto
+)
+
+// cassetteEntry is a recorded interaction, stored as a line of a cassette.
+type cassetteEntry struct {
+	Key string `json:"key"`
+	// Thread is the digest of the previous requests of the thread the request
+	// was executed in, if any.
+	Thread   string          `json:"thread,omitempty"`
+	Request  json.RawMessage `json:"request"`
+	Response *InnerResponse  `json:"response"`
+}
+
+// recordedThread tracks the requests executed in a thread, to match requests on
+// the history of their thread.
+type recordedThread struct {
+	// digest chains the keys of the requests executed in the thread.
+	digest string
+	// replayed is set when a request of the thread was replayed.
+	replayed bool
+}
+
+// Recorder is an `Llm` wrapping a provider to record its interactions to a
+// JSONL file (a "cassette"), and replay them later, without calling the
+// provider.
+//
+// Recorded requests are matched on their content: model, messages, schema,
+// tools and sampling parameters. When the same request was recorded several
+// times, the responses are replayed in the order they were recorded, the last
+// one being replayed once exhausted.
+//
+// Requests executed in a thread are also matched on the requests previously
+// executed in it, so turns of different threads with the same messages are told
+// apart, regardless of the order threads are executed in.
+type Recorder struct {
+	provider Llm
+	mode     RecordMode
+	path     string
+
+	mtx          sync.Mutex
+	interactions map[string][]*InnerResponse
+	cursors      map[string]int
+	threads      map[*ThreadId]recordedThread
+}
+
+// NewRecorder wraps a provider to record or replay its interactions with the
+// cassette at the provided path.
+//
+// The provider can be nil in `RecordModeReplay`, so tests can run without
+// credentials.
+func NewRecorder(provider Llm, cassette string, mode RecordMode) (*Recorder, error) {
+	r := Recorder{
+		provider:     provider,
+		mode:         mode,
+		path:         cassette,
+		interactions: make(map[string][]*InnerResponse),
+		cursors:      make(map[string]int),
+		threads:      make(map[*ThreadId]recordedThread),
+	}
+
+	if provider == nil && mode != RecordModeReplay {
+		return nil, errors.New("a provider is required to record interactions")
+	}
+
+	switch mode {
+	case RecordModeRecord:
+		if err := os.WriteFile(cassette, nil, 0o644); err != nil {
+			return nil, errors.Wrap(err, "could not create cassette")
+		}
+
+	default:
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+func (r *Recorder) load() error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && r.mode == RecordModeAuto {
+			return nil
+		}
+
+		return errors.Wrap(err, "could not open cassette")
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry cassetteEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return errors.Wrapf(err, "could not decode cassette line %d", line)
+		}
+
+		r.interactions[entry.Key] = append(r.interactions[entry.Key], entry.Response)
+	}
+
+	return errors.Wrap(scanner.Err(), "could not read cassette")
+}
+
+func (r *Recorder) Init(llm internal.Adapter) error {
+	if r.provider == nil {
+		return nil
+	}
+
+	return r.provider.Init(llm)
+}
+
+func (r *Recorder) ResetThread(threadId *ThreadId) {
+	r.forgetThread(threadId)
+
+	if r.provider != nil {
+		r.provider.ResetThread(threadId)
+	}
+}
+
+// CopyThread delegates to the wrapped provider. In pure replay mode, where
+// thread history is not kept, it returns a new thread bound to the recorder.
+func (r *Recorder) CopyThread(threadId *ThreadId) *ThreadId {
+	copied := &ThreadId{provider: r}
+
+	if r.provider != nil {
+		copied = r.provider.CopyThread(threadId)
+	}
+
+	if copied != nil {
+		r.mtx.Lock()
+		r.threads[copied] = r.threads[threadId]
+		r.mtx.Unlock()
+	}
+
+	return copied
+}
+
+func (r *Recorder) CloseThread(threadId *ThreadId) {
+	r.forgetThread(threadId)
+
+	if r.provider != nil {
+		r.provider.CloseThread(threadId)
+	}
+}
+
+func (r *Recorder) forgetThread(threadId *ThreadId) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.threads, threadId)
+}
+
+// Unwrap returns the wrapped provider. Optional provider interfaces, such as
+// `HistoryCompactor`, are only supported by the recorder when the wrapped
+// provider implements them.
+func (r *Recorder) Unwrap() Llm {
+	return r.provider
+}
+
+func (r *Recorder) RequestOptionsType() reflect.Type {
+	if r.provider == nil {
+		return nil
+	}
+
+	return r.provider.RequestOptionsType()
+}
+
+func (r *Recorder) ChatCompletion(ctx context.Context, llm internal.Adapter, requester Requester) (*InnerResponse, error) {
+	return r.interact(llm, requester, nil, func() (*InnerResponse, error) {
+		return r.provider.ChatCompletion(ctx, llm, requester)
+	})
+}
+
+// ChatCompletionStream replays recorded responses as one delta per candidate.
+// Recording a stream requires the wrapped provider to support streaming.
+func (r *Recorder) ChatCompletionStream(ctx context.Context, llm internal.Adapter, requester Requester, onDelta func(StreamDelta) bool) (*InnerResponse, error) {
+	return r.interact(llm, requester, onDelta, func() (*InnerResponse, error) {
+		streamer, ok := r.provider.(StreamingLlm)
+		if !ok {
+			return nil, errors.New("provider does not support streaming")
+		}
+
+		return streamer.ChatCompletionStream(ctx, llm, requester, onDelta)
+	})
+}
+
+// Render delegates to the wrapped provider, if it supports rendering requests.
+func (r *Recorder) Render(llm internal.Adapter, requester Requester) (json.RawMessage, error) {
+	renderer, ok := r.provider.(Renderer)
+	if !ok {
+		return nil, errors.New("provider does not support rendering requests")
+	}
+
+	return renderer.Render(llm, requester)
+}
+
+// CountTokens delegates to the wrapped provider, if it supports counting tokens.
+func (r *Recorder) CountTokens(ctx context.Context, llm internal.Adapter, requester Requester) (TokenCount, error) {
+	counter, ok := r.provider.(TokenCounter)
+	if !ok {
+		return TokenCount{}, errors.New("provider does not support counting tokens")
+	}
+
+	return counter.CountTokens(ctx, llm, requester)
+}
+
+// DefaultModel delegates to the wrapped provider, if it has a default model.
+func (r *Recorder) DefaultModel() string {
+	if defaulter, ok := r.provider.(ModelDefaulter); ok {
+		return defaulter.DefaultModel()
+	}
+
+	return ""
+}
+
+// SystemName delegates to the wrapped provider, if it names its system.
+func (r *Recorder) SystemName() string {
+	if namer, ok := r.provider.(SystemNamer); ok {
+		return namer.SystemName()
+	}
+
+	return ""
+}
+
+// ThreadHistory delegates to the wrapped provider, if it supports history
+// policies.
+func (r *Recorder) ThreadHistory(threadId *ThreadId) []HistoryMessage {
+	if compactor, ok := r.provider.(HistoryCompactor); ok {
+		return compactor.ThreadHistory(threadId)
+	}
+
+	return nil
+}
+
+// CompactThread delegates to the wrapped provider, if it supports history
+// policies.
+func (r *Recorder) CompactThread(threadId *ThreadId, count int, summary string) {
+	if compactor, ok := r.provider.(HistoryCompactor); ok {
+		compactor.CompactThread(threadId, count, summary)
+	}
+}
+
+func (r *Recorder) interact(llm internal.Adapter, requester Requester, onDelta func(StreamDelta) bool, call func() (*InnerResponse, error)) (*InnerResponse, error) {
+	req := requester.ToRequest()
+
+	canonical, ok := newCanonicalRequest("", resolveModel(llm, r, req), req)
+	if !ok {
+		return nil, errors.New("request cannot be recorded: message parts must be seekable")
+	}
+
+	key, ok := canonical.hash()
+	if !ok {
+		return nil, errors.New("request cannot be recorded: could not encode it")
+	}
+
+	thread := r.thread(req.ThreadId)
+
+	if req.ThreadId != nil {
+		key = chainDigest(thread.digest, key)
+	}
+
+	if r.mode != RecordModeRecord {
+		if resp, ok := r.replay(key); ok {
+			r.advanceThread(req.ThreadId, key, true)
+
+			for idx := range resp.Candidates {
+				if onDelta != nil && !onDelta(cachedDelta(idx, resp.Candidates[idx])) {
+					return nil, ErrStreamStopped
+				}
+			}
+
+			return resp, nil
+		}
+
+		if r.mode == RecordModeReplay {
+			return nil, ErrNotRecorded
+		}
+		if thread.replayed {
+			return nil, errors.Wrap(ErrNotRecorded, "previous requests of the thread were replayed, so it cannot be recorded")
+		}
+	}
+
+	resp, err := call()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.record(key, thread.digest, canonical, resp); err != nil {
+		return nil, err
+	}
+
+	r.advanceThread(req.ThreadId, key, false)
+
+	return resp, nil
+}
+
+// thread returns the requests previously executed in a thread. The first
+// request of a thread is chained to an empty digest, so it does not match the
+// same request executed outside of a thread.
+func (r *Recorder) thread(threadId *ThreadId) recordedThread {
+	if threadId == nil {
+		return recordedThread{}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.threads[threadId]
+}
+
+// advanceThread records that a request was executed in a thread.
+func (r *Recorder) advanceThread(threadId *ThreadId, key string, replayed bool) {
+	if threadId == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	thread := r.threads[threadId]
+	thread.digest = key
+	thread.replayed = thread.replayed || replayed
+
+	r.threads[threadId] = thread
+}
+
+// replay returns a copy of the next recorded response for a request key.
+func (r *Recorder) replay(key string) (*InnerResponse, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	responses, ok := r.interactions[key]
+	if !ok || len(responses) == 0 {
+		return nil, false
+	}
+
+	idx := min(r.cursors[key], len(responses)-1)
+	r.cursors[key] += 1
+
+	resp := *responses[idx]
+	resp.Candidates = make([]ResponseCandidate, len(responses[idx].Candidates))
+
+	for i, candidate := range responses[idx].Candidates {
+		candidate.SelectCandidate = func() {}
+		resp.Candidates[i] = candidate
+	}
+
+	return &resp, true
+}
+
+func (r *Recorder) record(key, thread string, canonical *canonicalRequest, resp *InnerResponse) error {
+	request, err := json.Marshal(canonical)
+	if err != nil {
+		return errors.Wrap(err, "could not encode recorded request")
+	}
+
+	line, err := json.Marshal(cassetteEntry{Key: key, Thread: thread, Request: request, Response: resp})
+	if err != nil {
+		return errors.Wrap(err, "could not encode recorded interaction")
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "could not open cassette")
+	}
+
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "could not write to cassette")
+	}
+
+	return nil
+}
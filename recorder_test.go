@@ -0,0 +1,227 @@
+package llmberjack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecorder(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	recorder, err := NewRecorder(p, cassette, RecordModeRecord)
+
+	assert.Nil(t, err)
+
+	llm, _ := New(WithDefaultProvider(recorder), WithDefaultModel("themodel"))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello again"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hi"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Goodbye"}, nil).Once()
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	resp, _ := NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+	resp.Candidates[0].SelectCandidate()
+
+	_, _ = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Goodbye").Do(t.Context(), llm)
+
+	// Threads are still handled by the wrapped provider while recording.
+	assert.Len(t, p.History.Load(resp.ThreadId), 3)
+
+	buf, _ := os.ReadFile(cassette)
+
+	assert.Len(t, strings.Split(strings.TrimSpace(string(buf)), "\n"), 4)
+
+	replayer, err := NewRecorder(nil, cassette, RecordModeReplay)
+
+	assert.Nil(t, err)
+
+	llm, _ = New(WithDefaultProvider(replayer), WithDefaultModel("themodel"))
+
+	for _, expected := range []string{"Hello", "Hello again", "Hello again"} {
+		resp, err := NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, resp.Candidates[0].Text)
+		assert.NotPanics(t, func() { resp.Candidates[0].SelectCandidate() })
+	}
+
+	// Requests in a thread are matched on the previous requests of the thread.
+	_, err = NewUntypedRequest().CreateThread().WithText(RoleUser, "Goodbye").Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrNotRecorded)
+
+	resp, err = NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hi", resp.Candidates[0].Text)
+
+	// Threads copied in replay mode are bound to the recorder, and keep the
+	// requests of the original thread.
+	thread := resp.ThreadId.Copy()
+
+	assert.NotNil(t, thread)
+
+	for _, threadId := range []*ThreadId{resp.ThreadId, thread} {
+		resp, err := NewUntypedRequest().InThread(threadId).WithText(RoleUser, "Goodbye").Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Goodbye", resp.Candidates[0].Text)
+	}
+
+	assert.NotPanics(t, thread.Clear)
+	assert.NotPanics(t, thread.Close)
+
+	_, err = NewUntypedRequest().WithText(RoleUser, "Hello").WithModel("othermodel").Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrNotRecorded)
+
+	stream, _ := NewUntypedRequest().WithText(RoleUser, "Hello").Stream(t.Context(), llm)
+
+	for delta, err := range stream {
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello again", delta.Text)
+	}
+
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+}
+
+func TestRecorderAuto(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	recorder, err := NewRecorder(p, cassette, RecordModeAuto)
+
+	assert.Nil(t, err)
+
+	llm, _ := New(WithDefaultProvider(recorder))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, errors.New("provider error")).Once()
+	p.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "provider error")
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	recorder, _ = NewRecorder(p, cassette, RecordModeAuto)
+	llm, _ = New(WithDefaultProvider(recorder))
+
+	resp, err := NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 2)
+
+	// Threads are recorded, then replayed.
+	resp, err = NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+
+	resp.Candidates[0].SelectCandidate()
+
+	_, err = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Next").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+
+	recorder, _ = NewRecorder(p, cassette, RecordModeAuto)
+	llm, _ = New(WithDefaultProvider(recorder))
+
+	resp, err = NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	_, err = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Next").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+
+	// New requests are refused in a thread whose previous requests were
+	// replayed, since they are missing from the history of the provider.
+	_, err = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Unknown").Do(t.Context(), llm)
+
+	assert.ErrorIs(t, err, ErrNotRecorded)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 4)
+
+	_, err = NewRecorder(nil, filepath.Join(t.TempDir(), "missing.jsonl"), RecordModeReplay)
+
+	assert.ErrorContains(t, err, "could not open cassette")
+
+	_, err = NewRecorder(nil, cassette, RecordModeRecord)
+
+	assert.ErrorContains(t, err, "a provider is required")
+}
+
+func TestRecorderThreads(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	recorder, _ := NewRecorder(p, cassette, RecordModeRecord)
+	llm, _ := New(WithDefaultProvider(recorder))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Red"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Apple"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Blue"}, nil).Once()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Sky"}, nil).Once()
+
+	for _, color := range []string{"Red", "Blue"} {
+		resp, _ := NewUntypedRequest().CreateThread().WithText(RoleUser, color).Do(t.Context(), llm)
+		resp.Candidates[0].SelectCandidate()
+
+		_, _ = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Next").Do(t.Context(), llm)
+	}
+
+	replayer, _ := NewRecorder(nil, cassette, RecordModeReplay)
+	llm, _ = New(WithDefaultProvider(replayer))
+
+	// Threads are replayed in a different order than they were recorded in.
+	for color, expected := range map[string]string{"Blue": "Sky", "Red": "Apple"} {
+		resp, err := NewUntypedRequest().CreateThread().WithText(RoleUser, color).Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+
+		resp, err = NewUntypedRequest().InThread(resp.ThreadId).WithText(RoleUser, "Next").Do(t.Context(), llm)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, resp.Candidates[0].Text)
+	}
+}
+
+func TestRecorderUnsupported(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	recorder, _ := NewRecorder(p, filepath.Join(t.TempDir(), "cassette.jsonl"), RecordModeRecord)
+	llm, _ := New(WithDefaultProvider(recorder))
+
+	// The wrapped provider does not support history policies nor counting tokens.
+	threadId := &ThreadId{provider: recorder}
+	threadId.SetHistoryPolicy(HistoryPolicy{MaxTurns: 1})
+
+	_, err := NewUntypedRequest().InThread(threadId).WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "provider does not support history policies")
+
+	_, err = NewUntypedRequest().WithText(RoleUser, "Hello").CountTokens(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "provider does not support counting tokens")
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
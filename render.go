@@ -0,0 +1,39 @@
+package llmberjack
+
+import (
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Render returns the payload that would be sent to the provider for the
+// request, as JSON, without sending it. It can be used to inspect what is
+// actually sent to a provider, or in golden-file tests of prompts.
+//
+// The payload includes the history of the thread the request is executed in,
+// which is left untouched. Message parts that cannot be rewound, such as
+// readers which are not `io.Seeker`, are consumed by rendering the request.
+//
+// Middlewares, retries and fallback providers are not involved in rendering.
+func (r Request[T]) Render(llm *Llmberjack) (json.RawMessage, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	provider, req, err := r.resolve(llm, r.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, ok := supports[Renderer](provider)
+	if !ok {
+		return nil, errors.New("provider does not support rendering requests")
+	}
+
+	payload, err := renderer.Render(llm, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not render request")
+	}
+
+	return payload, nil
+}
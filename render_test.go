@@ -0,0 +1,55 @@
+package llmberjack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRenderer struct {
+	*MockProvider
+}
+
+func (p mockRenderer) Render(_ internal.Adapter, requester Requester) (json.RawMessage, error) {
+	req := requester.ToRequest()
+
+	return json.Marshal(map[string]any{
+		"model":    *req.Model,
+		"messages": len(req.Messages),
+	})
+}
+
+func TestRender(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(mockRenderer{p}))
+
+	payload, err := NewUntypedRequest().
+		WithModel("themodel").
+		WithText(RoleUser, "prompt").
+		Render(llm)
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"model": "themodel", "messages": 1}`, string(payload))
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	_, err := NewUntypedRequest().Render(llm)
+
+	assert.ErrorContains(t, err, "provider does not support rendering requests")
+
+	_, err = NewUntypedRequest().WithImage(RoleUser, strings.NewReader("image"), "text/plain").Render(llm)
+
+	assert.ErrorContains(t, err, "is not an image MIME type")
+}
@@ -5,9 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"log/slog"
+	"mime"
+	"net/url"
 	"os"
+	"path"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/checkmarble/llmberjack/internal"
 	"github.com/cockroachdb/errors"
@@ -29,6 +34,9 @@ const (
 
 const (
 	TypeText MessageType = iota
+	TypeImage
+	TypeDocument
+	TypeAudio
 )
 
 // Requester represents something that can be turned into a request.
@@ -53,6 +61,12 @@ type Message struct {
 	Role MessageRole
 	// Parts are subdivision of a specific message.
 	Parts []io.Reader
+	// MimeType is the media type of the parts of binary messages.
+	MimeType string
+	// Url references remote content, sent instead of the parts.
+	Url string
+	// Filename is the name of the file documents were read from.
+	Filename string
 
 	// Tool is an instruction from a tool function to be called. This only makes
 	// sense in response messages.
@@ -76,14 +90,28 @@ type innerRequest struct {
 	SchemaDescription string
 	SchemaOverride    *jsonschema.Schema
 
-	MaxTokens     *int
-	MaxCandidates *int
-	Temperature   *float64
-	TopP          *float64
+	MaxTokens        *int
+	MaxCandidates    *int
+	Temperature      *float64
+	TopP             *float64
+	StopSequences    []string
+	Seed             *int
+	PresencePenalty  *float64
+	FrequencyPenalty *float64
+	// Logprobs is the number of most likely alternatives to return with the
+	// log probability of each generated token. If nil, log probabilities are
+	// not requested.
+	Logprobs *int
 
 	// Thinking is a flag to enable/disable thinking. If not provided, the provider will use its default behavior.
 	Thinking *bool
 
+	RetryPolicy *RetryPolicy
+
+	NoCache       bool
+	CacheInThread bool
+	CacheTTL      *time.Duration
+
 	ProviderOptions map[reflect.Type]internal.ProviderRequestOptions
 }
 
@@ -99,10 +127,12 @@ type innerRequest struct {
 type Request[T any] struct {
 	innerRequest
 
-	provider        *string
-	createNewThread bool
-	respondsTo      *ResponseCandidate
-	err             error
+	provider          *string
+	fallbackProviders []string
+	createNewThread   bool
+	respondsTo        *ResponseCandidate
+	toolExecutions    []toolExecution
+	err               error
 }
 
 // NewUntypedRequest is a helper method to create a `Request` which will be a
@@ -152,17 +182,135 @@ func NewRequest[T any]() Request[T] {
 // It will return a response generic over the configured typed on the Request,
 // or an error.
 func (r Request[T]) Do(ctx context.Context, llm *Llmberjack) (*Response[T], error) {
+	return r.execute(ctx, llm, nil)
+}
+
+// execute sends the request to the selected provider, and to the fallback
+// providers in turn, as long as they fail with a retryable error.
+//
+// When streaming, fallback providers are only tried if no delta was emitted.
+func (r Request[T]) execute(ctx context.Context, llm *Llmberjack, onDelta func(StreamDelta) bool) (*Response[T], error) {
+	ctx, span := r.startSpan(ctx, llm)
+	defer span.End()
+
+	// Invalid requests are still traced, so that the execution of the tools
+	// that failed while building them is recorded.
 	if r.err != nil {
+		traceError(span, r.err)
+
 		return nil, r.err
 	}
 
-	provider, err := llm.GetProvider(r.provider)
+	start := time.Now()
+	emitted := false
+
+	if onDelta != nil {
+		inner := onDelta
+
+		onDelta = func(delta StreamDelta) bool {
+			emitted = true
+
+			return inner(delta)
+		}
+	}
+
+	var (
+		errs  error
+		name  string
+		model string
+	)
+
+	chain, err := r.providerChain(llm)
 	if err != nil {
+		traceError(span, err)
+		llm.log(ctx, slog.LevelError, "LLM request failed", slog.String("provider", llm.providerName(r.provider)), threadAttr(r.ThreadId), slog.String("error", err.Error()))
+
 		return nil, err
 	}
 
+	for idx, providerName := range chain {
+		name = llm.providerName(providerName)
+
+		provider, req, err := r.resolve(llm, providerName)
+		if err != nil {
+			err = errors.CombineErrors(err, errs)
+
+			traceError(span, err)
+			llm.log(ctx, slog.LevelError, "LLM request failed", slog.String("provider", name), threadAttr(r.ThreadId), slog.String("error", err.Error()))
+
+			return nil, err
+		}
+
+		if err := llm.checkBudgets(ctx, req.ThreadId); err != nil {
+			err = errors.CombineErrors(err, errs)
+
+			traceError(span, err)
+			llm.log(ctx, slog.LevelWarn, "LLM request refused", slog.String("provider", name), threadAttr(req.ThreadId), slog.String("error", err.Error()))
+
+			return nil, err
+		}
+
+		if err := llm.compactHistory(ctx, provider, req.ThreadId); err != nil {
+			traceError(span, err)
+			llm.log(ctx, slog.LevelError, "LLM request failed", slog.String("provider", name), threadAttr(req.ThreadId), slog.String("error", err.Error()))
+
+			return nil, err
+		}
+
+		model = resolveModel(llm, provider, req.innerRequest)
+
+		if idx > 0 {
+			traceFallback(span, name, errs)
+			llm.log(ctx, slog.LevelWarn, "falling back to next LLM provider", slog.String("provider", name), slog.String("error", errs.Error()))
+		}
+
+		llm.traceRequest(span, name, provider, model, req.innerRequest)
+		llm.logRequest(ctx, name, model, req.innerRequest)
+
+		resp, err := llm.handler(ctx, Invocation{
+			ProviderName: name,
+			Provider:     provider,
+			Requester:    req,
+			OnDelta:      onDelta,
+		})
+
+		if err == nil {
+			cost := llm.recordCost(name, provider, req.ThreadId, resp)
+
+			llm.recordBudgets(ctx, req.ThreadId, resp, cost)
+			llm.traceResponse(span, resp, cost)
+			llm.logResponse(ctx, name, req.ThreadId, resp, time.Since(start))
+
+			return &Response[T]{
+				InnerResponse: *resp,
+				ThreadId:      req.ThreadId,
+				Cost:          cost,
+			}, nil
+		}
+
+		errs = errors.CombineErrors(err, errs)
+
+		if idx == len(chain)-1 || emitted || ctx.Err() != nil || !IsRetryable(err) {
+			break
+		}
+	}
+
+	traceError(span, errs)
+	llm.log(ctx, failureLevel(errs), "LLM request failed", slog.String("provider", name), slog.String("model", model), threadAttr(r.ThreadId), slog.Duration("latency", time.Since(start)), slog.String("error", errs.Error()))
+
+	return nil, errs
+}
+
+// resolve selects the provider to execute the request on, and finalizes the
+// request for it (model selection and thread creation).
+func (r Request[T]) resolve(llm *Llmberjack, providerName *string) (Llm, Request[T], error) {
+	provider, err := llm.GetProvider(providerName)
+	if err != nil {
+		return nil, r, err
+	}
+
 	if r.ModelFunc != nil {
-		if m := r.ModelFunc(provider, r.provider); m != "" {
+		if m := r.ModelFunc(provider, providerName); m != "" {
 			r.Model = &m
 		}
 	}
@@ -174,18 +322,10 @@ func (r Request[T]) Do(ctx context.Context, llm *Llmberjack) (*Response[T], erro
 	}
 
 	if r.ThreadId != nil && r.ThreadId.provider != provider {
-		return nil, errors.New("thread was not produced by provider")
+		return nil, r, errors.New("thread was not produced by provider")
 	}
 
-	resp, err := provider.ChatCompletion(ctx, llm, r)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Response[T]{
-		InnerResponse: *resp,
-		ThreadId:      r.ThreadId,
-	}, nil
+	return provider, r, nil
 }
 
 func (r Request[T]) WithProvider(name string) Request[T] {
@@ -349,6 +489,93 @@ func (r Request[T]) WithTextReader(role MessageRole, parts ...io.Reader) Request
 	return r
 }
 
+// WithImage adds an image to the Request, read from an io.Reader.
+//
+// The MIME type must be an image type, such as `image/png` or `image/jpeg`.
+func (r Request[T]) WithImage(role MessageRole, image io.Reader, mimeType string) Request[T] {
+	if !strings.HasPrefix(mimeType, "image/") {
+		r.err = errors.CombineErrors(r.err, errors.Newf("'%s' is not an image MIME type", mimeType))
+		return r
+	}
+
+	r.Messages = append(r.Messages, Message{
+		Type:     TypeImage,
+		Role:     role,
+		Parts:    []io.Reader{image},
+		MimeType: mimeType,
+	})
+
+	return r
+}
+
+// WithImageUrl adds an image to the Request, referenced by its URL.
+//
+// The provider will retrieve the image itself, so it must be reachable from it.
+// The MIME type of the image is guessed from the extension of the URL.
+func (r Request[T]) WithImageUrl(role MessageRole, imageUrl string) Request[T] {
+	u, err := url.Parse(imageUrl)
+	if err != nil {
+		r.err = errors.CombineErrors(r.err, errors.Wrap(err, "invalid image URL"))
+		return r
+	}
+
+	r.Messages = append(r.Messages, Message{
+		Type:     TypeImage,
+		Role:     role,
+		MimeType: mime.TypeByExtension(path.Ext(u.Path)),
+		Url:      imageUrl,
+	})
+
+	return r
+}
+
+// WithAudio adds an audio recording to the Request, read from an io.Reader.
+//
+// The MIME type must be an audio type, such as `audio/wav` or `audio/mpeg`.
+// Providers do not all accept the same audio formats, and will fail with
+// `ErrUnsupportedContent` if they cannot process the provided one.
+//
+// The recording is read when the request is sent, and providers keep the
+// content they were sent in the history of threads, so it does not need to be
+// read again on subsequent turns.
+func (r Request[T]) WithAudio(role MessageRole, audio io.Reader, mimeType string) Request[T] {
+	if !strings.HasPrefix(mimeType, "audio/") {
+		r.err = errors.CombineErrors(r.err, errors.Newf("'%s' is not an audio MIME type", mimeType))
+		return r
+	}
+
+	r.Messages = append(r.Messages, Message{
+		Type:     TypeAudio,
+		Role:     role,
+		Parts:    []io.Reader{audio},
+		MimeType: mimeType,
+	})
+
+	return r
+}
+
+// WithFile adds a document to the Request, such as a PDF file, read from an
+// io.Reader.
+//
+// Providers do not all accept the same document types, and will fail with
+// `ErrUnsupportedContent` if they cannot process the provided MIME type.
+func (r Request[T]) WithFile(role MessageRole, file io.Reader, mimeType, filename string) Request[T] {
+	if _, _, err := mime.ParseMediaType(mimeType); err != nil {
+		r.err = errors.CombineErrors(r.err, errors.Newf("'%s' is not a valid MIME type", mimeType))
+		return r
+	}
+
+	r.Messages = append(r.Messages, Message{
+		Type:     TypeDocument,
+		Role:     role,
+		Parts:    []io.Reader{file},
+		MimeType: mimeType,
+		Filename: filename,
+	})
+
+	return r
+}
+
 func (r Request[T]) WithSerializable(role MessageRole, ser Serializer, input any) Request[T] {
 	var buf bytes.Buffer
 
@@ -449,7 +676,11 @@ func (r Request[T]) WithToolExecution(tools ...internal.Tool) Request[T] {
 			return r
 		}
 
+		start := time.Now()
 		resp, err := tool.Call(toolCall.Parameters)
+
+		r.toolExecutions = append(r.toolExecutions, toolExecution{call: toolCall, start: start, end: time.Now(), err: err})
+
 		if err != nil {
 			r.err = errors.CombineErrors(r.err, err)
 			return r
@@ -504,12 +735,86 @@ func (r Request[T]) WithTopP(topp float64) Request[T] {
 	return r
 }
 
+// WithStopSequences sets sequences at which the provider stops generating its
+// completion. The stop sequences are not included in the completion.
+//
+// Providers limit how many stop sequences can be set, and will fail with
+// `ErrUnsupportedOption` if there are too many.
+func (r Request[T]) WithStopSequences(sequences ...string) Request[T] {
+	r.StopSequences = append(r.StopSequences, sequences...)
+
+	return r
+}
+
+// WithSeed sets the seed used to sample the completion, so that repeated
+// requests with the same seed and parameters return the same completion.
+//
+// Determinism is best effort, and is not guaranteed by the providers.
+func (r Request[T]) WithSeed(seed int) Request[T] {
+	r.Seed = &seed
+
+	return r
+}
+
+// WithPresencePenalty sets the `presence_penalty` parameter, between -2 and 2.
+// Positive values penalize tokens that already appeared in the completion,
+// encouraging the model to use new ones.
+func (r Request[T]) WithPresencePenalty(penalty float64) Request[T] {
+	if penalty < -2 || penalty > 2 {
+		r.err = errors.CombineErrors(r.err, errors.Newf("presence penalty must be between -2 and 2, got %f", penalty))
+		return r
+	}
+
+	r.PresencePenalty = &penalty
+
+	return r
+}
+
+// WithFrequencyPenalty sets the `frequency_penalty` parameter, between -2 and
+// 2. Positive values penalize tokens proportionally to how often they already
+// appeared in the completion, reducing repetitions.
+func (r Request[T]) WithFrequencyPenalty(penalty float64) Request[T] {
+	if penalty < -2 || penalty > 2 {
+		r.err = errors.CombineErrors(r.err, errors.Newf("frequency penalty must be between -2 and 2, got %f", penalty))
+		return r
+	}
+
+	r.FrequencyPenalty = &penalty
+
+	return r
+}
+
+// WithLogprobs requests the log probabilities of the generated tokens, along
+// with the `topN` most likely alternatives at each position, to be returned in
+// `ResponseCandidate.Logprobs`.
+//
+// Providers limit how many alternatives can be returned, and will fail with
+// `ErrUnsupportedOption` if there are too many.
+func (r Request[T]) WithLogprobs(topN int) Request[T] {
+	if topN < 0 {
+		r.err = errors.CombineErrors(r.err, errors.Newf("number of log probabilities must be positive, got %d", topN))
+		return r
+	}
+
+	r.Logprobs = &topN
+
+	return r
+}
+
 func (r Request[T]) WithThinking(thinking bool) Request[T] {
 	r.Thinking = &thinking
 
 	return r
 }
 
+// WithRetryPolicy overrides the retry policy configured on the adapter for this
+// request.
+func (r Request[T]) WithRetryPolicy(policy RetryPolicy) Request[T] {
+	r.RetryPolicy = &policy
+
+	return r
+}
+
 // Request[T] implementation of Requester.
 
 func (r Request[T]) ToRequest() innerRequest {
@@ -64,6 +64,89 @@ func TestNewRequest(t *testing.T) {
 	assertParts(t, req.Messages[3].Parts, "reader user prompt")
 }
 
+func TestRequestWithImages(t *testing.T) {
+	req := NewUntypedRequest().
+		WithImage(RoleUser, strings.NewReader("image"), "image/png").
+		WithImageUrl(RoleUser, "https://example.com/path/image.webp?size=large")
+
+	assert.Nil(t, req.err)
+	assert.Len(t, req.Messages, 2)
+	assert.Equal(t, TypeImage, req.Messages[0].Type)
+	assert.Equal(t, "image/png", req.Messages[0].MimeType)
+	assertParts(t, req.Messages[0].Parts, "image")
+	assert.Equal(t, TypeImage, req.Messages[1].Type)
+	assert.Equal(t, "image/webp", req.Messages[1].MimeType)
+	assert.Equal(t, "https://example.com/path/image.webp?size=large", req.Messages[1].Url)
+	assert.Empty(t, req.Messages[1].Parts)
+
+	req = NewUntypedRequest().WithImage(RoleUser, strings.NewReader("image"), "application/pdf")
+
+	assert.ErrorContains(t, req.err, "'application/pdf' is not an image MIME type")
+}
+
+func TestRequestWithAudio(t *testing.T) {
+	req := NewUntypedRequest().WithAudio(RoleUser, strings.NewReader("audio"), "audio/wav")
+
+	assert.Nil(t, req.err)
+	assert.Len(t, req.Messages, 1)
+	assert.Equal(t, TypeAudio, req.Messages[0].Type)
+	assert.Equal(t, "audio/wav", req.Messages[0].MimeType)
+	assertParts(t, req.Messages[0].Parts, "audio")
+
+	req = NewUntypedRequest().WithAudio(RoleUser, strings.NewReader("audio"), "video/mp4")
+
+	assert.ErrorContains(t, req.err, "'video/mp4' is not an audio MIME type")
+}
+
+func TestRequestWithFile(t *testing.T) {
+	req := NewUntypedRequest().WithFile(RoleUser, strings.NewReader("document"), "application/pdf", "document.pdf")
+
+	assert.Nil(t, req.err)
+	assert.Len(t, req.Messages, 1)
+	assert.Equal(t, TypeDocument, req.Messages[0].Type)
+	assert.Equal(t, "application/pdf", req.Messages[0].MimeType)
+	assert.Equal(t, "document.pdf", req.Messages[0].Filename)
+	assertParts(t, req.Messages[0].Parts, "document")
+
+	req = NewUntypedRequest().WithFile(RoleUser, strings.NewReader("document"), "", "document")
+
+	assert.ErrorContains(t, req.err, "'' is not a valid MIME type")
+}
+
+func TestRequestWithSampling(t *testing.T) {
+	req := NewUntypedRequest().
+		WithStopSequences("END").
+		WithStopSequences("STOP", "HALT").
+		WithSeed(42).
+		WithPresencePenalty(0.5).
+		WithFrequencyPenalty(-1)
+
+	assert.Nil(t, req.err)
+	assert.Equal(t, []string{"END", "STOP", "HALT"}, req.StopSequences)
+	assert.Equal(t, 42, *req.Seed)
+	assert.Equal(t, 0.5, *req.PresencePenalty)
+	assert.Equal(t, -1.0, *req.FrequencyPenalty)
+
+	req = NewUntypedRequest().WithPresencePenalty(3)
+
+	assert.ErrorContains(t, req.err, "presence penalty must be between -2 and 2")
+
+	req = NewUntypedRequest().WithFrequencyPenalty(-2.5)
+
+	assert.ErrorContains(t, req.err, "frequency penalty must be between -2 and 2")
+}
+
+func TestRequestWithLogprobs(t *testing.T) {
+	req := NewUntypedRequest().WithLogprobs(5)
+
+	assert.Nil(t, req.err)
+	assert.Equal(t, 5, *req.Logprobs)
+
+	req = NewUntypedRequest().WithLogprobs(-1)
+
+	assert.ErrorContains(t, req.err, "number of log probabilities must be positive")
+}
+
 func TestRequestWithError(t *testing.T) {
 	req := Request[string]{
 		err: errors.New("request error"),
@@ -31,6 +31,23 @@ type InnerResponse struct {
 	Model      string
 	Candidates []ResponseCandidate
 	Created    time.Time
+	Usage      Usage
+
+	// Cached indicates the response was served from the cache instead of the
+	// provider.
+	Cached bool
+}
+
+// Usage reports how many tokens were consumed by a request.
+//
+// Cached and reasoning tokens are breakdowns, and are respectively included in
+// the prompt and completion token counts. Not all providers report all values.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int
+	ReasoningTokens  int
+	TotalTokens      int
 }
 
 // ResponseCandidate represent a candidate response from a  provider.
@@ -40,10 +57,13 @@ type ResponseCandidate struct {
 	ToolCalls    []ResponseToolCall
 	Grounding    *ResponseGrounding
 	Thoughts     string
+	// Logprobs are the log probabilities of the tokens of the text, if they
+	// were requested with `WithLogprobs()`.
+	Logprobs []TokenLogprob
 
 	// SelectCandidate is a callback that is called when a candidate is
 	// "selected" (when the conversation will continue from it).
-	SelectCandidate func()
+	SelectCandidate func() `json:"-"`
 }
 
 type ResponseGrounding struct {
@@ -74,6 +94,9 @@ type Response[T any] struct {
 	InnerResponse
 
 	ThreadId *ThreadId
+	// Cost is the estimated cost of the request, from the pricing registry
+	// configured on the adapter.
+	Cost Cost
 }
 
 func (r Response[T]) NumCandidates() int {
@@ -0,0 +1,158 @@
+package llmberjack
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2.0
+)
+
+// DefaultRetryPolicy is a sensible retry policy, retrying a failed request up
+// to two times.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: defaultInitialBackoff,
+	MaxBackoff:     defaultMaxBackoff,
+	Multiplier:     defaultMultiplier,
+	Jitter:         0.2,
+}
+
+// RetryPolicy configures how requests failing with transient errors are
+// retried.
+//
+// Between each attempt, the policy waits for an exponentially-increasing
+// delay, unless the provider indicated how long to wait, in which case this
+// is honored.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. A
+	// value of zero or one disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay to wait before the first retry. Defaults to
+	// 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the computed delay between two attempts. Defaults to 30s.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the delay increases after each
+	// attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, by which each delay is randomly
+	// reduced, to avoid concurrent requests to retry in lockstep.
+	Jitter float64
+
+	// RetryIf decides whether an error should be retried. If not provided,
+	// `IsRetryable` is used.
+	RetryIf func(error) bool
+}
+
+// backoff computes the delay to wait after the given attempt (starting at 1)
+// failed with the given error.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	delay := min(time.Duration(float64(initial)*math.Pow(multiplier, float64(attempt-1))), maxBackoff)
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(delay))
+	}
+
+	var providerErr *ProviderError
+
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > delay {
+		delay = providerErr.RetryAfter
+	}
+
+	return delay
+}
+
+func (p RetryPolicy) shouldRetry(err error) bool {
+	// Retrying is pointless while the circuit is open, it will be rejected
+	// again.
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if p.RetryIf != nil {
+		return p.RetryIf(err)
+	}
+
+	return IsRetryable(err)
+}
+
+// retry wraps a handler to execute the retry policy configured on the request,
+// or on the adapter.
+//
+// Streamed invocations are only retried if no delta was emitted yet, and
+// invocations are not retried once a budget they are subject to is exhausted.
+func (llm *Llmberjack) retry(next Handler) Handler {
+	return func(ctx context.Context, inv Invocation) (*InnerResponse, error) {
+		policy := inv.Requester.ToRequest().RetryPolicy
+		if policy == nil {
+			policy = llm.retryPolicy
+		}
+
+		if policy == nil || policy.MaxAttempts <= 1 {
+			return next(ctx, inv)
+		}
+
+		emitted := false
+
+		if inv.Streaming() {
+			onDelta := inv.OnDelta
+
+			inv.OnDelta = func(delta StreamDelta) bool {
+				emitted = true
+
+				return onDelta(delta)
+			}
+		}
+
+		for attempt := 1; ; attempt++ {
+			resp, err := next(ctx, inv)
+			if err == nil {
+				return resp, nil
+			}
+
+			if attempt >= policy.MaxAttempts || emitted || ctx.Err() != nil || !policy.shouldRetry(err) {
+				return nil, err
+			}
+
+			delay := policy.backoff(attempt, err)
+			timer := time.NewTimer(delay)
+
+			traceRetry(ctx, attempt, delay, err)
+			llm.log(ctx, slog.LevelWarn, "retrying LLM request", slog.String("provider", inv.ProviderName), slog.Int("attempt", attempt), slog.Duration("delay", delay), slog.String("error", err.Error()))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+
+			if budgetErr := llm.checkBudgets(ctx, inv.Requester.ToRequest().ThreadId); budgetErr != nil {
+				return nil, errors.CombineErrors(budgetErr, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package llmberjack
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+
+	assert.Equal(t, time.Second, policy.backoff(1, nil))
+	assert.Equal(t, 2*time.Second, policy.backoff(2, nil))
+	assert.Equal(t, 4*time.Second, policy.backoff(3, nil))
+	assert.Equal(t, 5*time.Second, policy.backoff(4, nil))
+	assert.Equal(t, 10*time.Second, policy.backoff(1, &ProviderError{RetryAfter: 10 * time.Second, Err: errors.New("error")}))
+
+	policy.Jitter = 0.5
+
+	for range 100 {
+		delay := policy.backoff(1, nil)
+
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}).Twice()
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil).Once()
+
+	resp, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", resp.Candidates[0].Text)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 3)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusTooManyRequests, Err: errors.New("rate limited")})
+
+	_, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "rate limited")
+	p.AssertNumberOfCalls(t, "ChatCompletion", 2)
+}
+
+func TestRetryNonRetryable(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusBadRequest, Err: errors.New("bad request")})
+
+	_, err := NewUntypedRequest().Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "bad request")
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestRetryRequestOverride(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+
+	_, err := NewUntypedRequest().WithRetryPolicy(RetryPolicy{MaxAttempts: 1}).Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "unavailable")
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := NewUntypedRequest().Do(ctx, llm)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	p.AssertNumberOfCalls(t, "ChatCompletion", 1)
+}
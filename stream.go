@@ -0,0 +1,119 @@
+package llmberjack
+
+import (
+	"context"
+	"iter"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrStreamStopped is returned by a provider when the consumer of a stream
+// stopped iterating before the stream was over.
+var ErrStreamStopped = errors.New("stream was stopped before completion")
+
+// StreamDelta is an incremental piece of a response being streamed.
+type StreamDelta struct {
+	// Candidate is the index of the candidate this delta belongs to.
+	Candidate int
+	Text      string
+	Thoughts  string
+	ToolCalls []StreamToolCallDelta
+}
+
+// StreamToolCallDelta is a fragment of a tool call being streamed.
+//
+// Depending on the provider, a tool call can be split across several deltas,
+// in which case fragments sharing the same index should be concatenated.
+type StreamToolCallDelta struct {
+	Index      int
+	Id         string
+	Name       string
+	Parameters string
+}
+
+// Stream executes a built request on the configured provider, streaming the
+// response as it is generated.
+//
+// The selected provider must support streaming. Fallback providers which do not
+// emit their whole response as one delta per candidate.
+//
+// The request is only sent when the returned sequence is iterated upon, and
+// the sequence can only be iterated over once. Once it was fully consumed, the
+// aggregated response can be retrieved with the returned function.
+//
+// Example usage:
+//
+//	deltas, response := llmberjack.NewUntypedRequest().
+//		WithText(llmberjack.RoleUser, "Tell me a story.").
+//		Stream(ctx, llm)
+//
+//	for delta, err := range deltas {
+//		fmt.Print(delta.Text)
+//	}
+//
+//	resp, err := response()
+func (r Request[T]) Stream(ctx context.Context, llm *Llmberjack) (iter.Seq2[StreamDelta, error], func() (*Response[T], error)) {
+	if err := r.checkStreaming(llm); err != nil {
+		deltas := func(yield func(StreamDelta, error) bool) {
+			yield(StreamDelta{}, err)
+		}
+
+		return deltas, func() (*Response[T], error) {
+			return nil, err
+		}
+	}
+
+	var (
+		consumed bool
+		response *Response[T]
+		err      = errors.New("stream was not consumed")
+	)
+
+	deltas := func(yield func(StreamDelta, error) bool) {
+		if consumed {
+			yield(StreamDelta{}, errors.New("stream was already consumed"))
+			return
+		}
+
+		consumed = true
+		stopped := false
+
+		response, err = r.execute(ctx, llm, func(delta StreamDelta) bool {
+			if !yield(delta, nil) {
+				stopped = true
+			}
+
+			return !stopped
+		})
+
+		if err != nil && !stopped {
+			yield(StreamDelta{}, err)
+		}
+	}
+
+	return deltas, func() (*Response[T], error) {
+		if err != nil {
+			return nil, err
+		}
+
+		return response, nil
+	}
+}
+
+// checkStreaming returns an error if a request cannot be streamed.
+func (r Request[T]) checkStreaming(llm *Llmberjack) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	provider, _, err := r.resolve(llm, r.provider)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := provider.(StreamingLlm); !ok {
+		return errors.New("provider does not support streaming")
+	}
+
+	return nil
+}
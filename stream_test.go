@@ -0,0 +1,136 @@
+package llmberjack
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStream(t *testing.T) {
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello, world, how are you?"}, nil)
+
+	stream, response := NewUntypedRequest().CreateThread().WithText(RoleUser, "Hello").Stream(t.Context(), llm)
+
+	_, err := response()
+
+	assert.ErrorContains(t, err, "stream was not consumed")
+
+	var sb strings.Builder
+	deltas := 0
+
+	for delta, err := range stream {
+		assert.Nil(t, err)
+
+		sb.WriteString(delta.Text)
+		deltas += 1
+	}
+
+	assert.Equal(t, 5, deltas)
+	assert.Equal(t, "Hello, world, how are you?", sb.String())
+
+	resp, err := response()
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.ThreadId)
+
+	output, err := resp.Get(0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello, world, how are you?", output)
+
+	for _, err := range stream {
+		assert.ErrorContains(t, err, "stream was already consumed")
+	}
+}
+
+func TestStreamStopped(t *testing.T) {
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello, world, how are you?"}, nil)
+
+	stream, response := NewUntypedRequest().Stream(t.Context(), llm)
+
+	for range stream {
+		break
+	}
+
+	_, err := response()
+
+	assert.ErrorIs(t, err, ErrStreamStopped)
+}
+
+func TestStreamErrors(t *testing.T) {
+	p := NewMockStreamingProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, errors.New("provider error"))
+
+	stream, response := NewUntypedRequest().Stream(t.Context(), llm)
+
+	for _, err := range stream {
+		assert.ErrorContains(t, err, "provider error")
+	}
+
+	_, err := response()
+
+	assert.ErrorContains(t, err, "provider error")
+
+	np := NewMockProvider()
+	np.On("Init", mock.Anything).Return(nil)
+
+	llm, _ = New(WithDefaultProvider(np))
+
+	stream, response = NewUntypedRequest().Stream(t.Context(), llm)
+
+	for _, err := range stream {
+		assert.ErrorContains(t, err, "provider does not support streaming")
+	}
+
+	_, err = response()
+
+	assert.ErrorContains(t, err, "provider does not support streaming")
+	np.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStreamFallback(t *testing.T) {
+	p1 := NewMockStreamingProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithProvider("p1", p1), WithProvider("p2", p2), WithFallbackProviders("p2"))
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello, world"}, nil)
+
+	stream, response := NewUntypedRequest().Stream(t.Context(), llm)
+
+	deltas := []string{}
+
+	for delta, err := range stream {
+		assert.Nil(t, err)
+
+		deltas = append(deltas, delta.Text)
+	}
+
+	assert.Equal(t, []string{"Hello, world"}, deltas)
+
+	resp, err := response()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello, world", resp.Candidates[0].Text)
+}
@@ -0,0 +1,84 @@
+package llmberjack
+
+import (
+	"bytes"
+	"io/fs"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+)
+
+// WithTemplate adds a text message to the Request, rendered from a template
+// executed with the provided data.
+//
+// The template is executed when building the request. If it references fields
+// or map keys that are missing from the data, the request will fail.
+func (r Request[T]) WithTemplate(role MessageRole, tmpl *template.Template, data any) Request[T] {
+	text, err := renderTemplate(tmpl, data)
+	if err != nil {
+		r.err = errors.CombineErrors(r.err, err)
+		return r
+	}
+
+	return r.WithText(role, text)
+}
+
+// WithInstructionTemplate adds a system prompt rendered from a template. See
+// `WithTemplate()`.
+func (r Request[T]) WithInstructionTemplate(tmpl *template.Template, data any) Request[T] {
+	return r.WithTemplate(RoleSystem, tmpl, data)
+}
+
+// WithTemplateFS adds a text message to the Request, rendered from the template
+// file at the provided path in a filesystem, such as an `embed.FS`. See
+// `WithTemplate()`.
+func (r Request[T]) WithTemplateFS(role MessageRole, fsys fs.FS, path string, data any) Request[T] {
+	tmpl, err := parseTemplateFS(fsys, path)
+	if err != nil {
+		r.err = errors.CombineErrors(r.err, err)
+		return r
+	}
+
+	return r.WithTemplate(role, tmpl, data)
+}
+
+// WithInstructionTemplateFS adds a system prompt rendered from the template
+// file at the provided path in a filesystem. See `WithTemplate()`.
+func (r Request[T]) WithInstructionTemplateFS(fsys fs.FS, path string, data any) Request[T] {
+	return r.WithTemplateFS(RoleSystem, fsys, path, data)
+}
+
+func parseTemplateFS(fsys fs.FS, path string) (*template.Template, error) {
+	buf, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read template '%s'", path)
+	}
+
+	tmpl, err := template.New(path).Parse(string(buf))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse template '%s'", path)
+	}
+
+	return tmpl, nil
+}
+
+// renderTemplate executes a template, failing if it references missing keys,
+// whichever options the template was created with.
+func renderTemplate(tmpl *template.Template, data any) (string, error) {
+	if tmpl == nil {
+		return "", errors.New("no template provided")
+	}
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", errors.Wrap(err, "could not clone template")
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Option("missingkey=error").Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "could not render template '%s'", tmpl.Name())
+	}
+
+	return buf.String(), nil
+}
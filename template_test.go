@@ -0,0 +1,77 @@
+package llmberjack
+
+import (
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestWithTemplate(t *testing.T) {
+	type Alert struct {
+		Customer string
+		Amount   int
+	}
+
+	tmpl := template.Must(template.New("alert").Parse("Review the transfer of {{.Amount}} by {{.Customer}}."))
+	instruction := template.Must(template.New("instruction").Parse("You work for {{.company}}."))
+
+	req := NewUntypedRequest().
+		WithInstructionTemplate(instruction, map[string]string{"company": "Marble"}).
+		WithTemplate(RoleUser, tmpl, Alert{Customer: "Bob", Amount: 1000})
+
+	assert.Nil(t, req.err)
+	assert.Len(t, req.Messages, 2)
+	assert.Equal(t, RoleSystem, req.Messages[0].Role)
+	assertParts(t, req.Messages[0].Parts, "You work for Marble.")
+	assert.Equal(t, RoleUser, req.Messages[1].Role)
+	assertParts(t, req.Messages[1].Parts, "Review the transfer of 1000 by Bob.")
+
+	t.Run("missing struct field", func(t *testing.T) {
+		tmpl := template.Must(template.New("alert").Parse("{{.Country}}"))
+		req := NewUntypedRequest().WithTemplate(RoleUser, tmpl, Alert{})
+
+		assert.ErrorContains(t, req.err, "could not render template 'alert'")
+		assert.Empty(t, req.Messages)
+	})
+
+	t.Run("missing map key", func(t *testing.T) {
+		req := NewUntypedRequest().WithInstructionTemplate(instruction, map[string]string{})
+
+		assert.ErrorContains(t, req.err, `map has no entry for key "company"`)
+	})
+
+	t.Run("no template", func(t *testing.T) {
+		req := NewUntypedRequest().WithTemplate(RoleUser, nil, nil)
+
+		assert.ErrorContains(t, req.err, "no template provided")
+	})
+}
+
+func TestRequestWithTemplateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"prompts/system.md": {Data: []byte("You are {{.Name}}.")},
+		"prompts/user.md":   {Data: []byte("Hello from {{.Name}}.")},
+		"prompts/broken.md": {Data: []byte("{{.Name")},
+	}
+
+	data := struct{ Name string }{Name: "Bob"}
+
+	req := NewUntypedRequest().
+		WithInstructionTemplateFS(fsys, "prompts/system.md", data).
+		WithTemplateFS(RoleUser, fsys, "prompts/user.md", data)
+
+	assert.Nil(t, req.err)
+	assert.Len(t, req.Messages, 2)
+	assertParts(t, req.Messages[0].Parts, "You are Bob.")
+	assertParts(t, req.Messages[1].Parts, "Hello from Bob.")
+
+	req = NewUntypedRequest().WithTemplateFS(RoleUser, fsys, "prompts/missing.md", data)
+
+	assert.ErrorContains(t, req.err, "could not read template 'prompts/missing.md'")
+
+	req = NewUntypedRequest().WithTemplateFS(RoleUser, fsys, "prompts/broken.md", data)
+
+	assert.ErrorContains(t, req.err, "could not parse template 'prompts/broken.md'")
+}
@@ -1,5 +1,7 @@
 package llmberjack
 
+import "sync/atomic"
+
 type noCopy struct{}
 
 func (*noCopy) Lock()   {}
@@ -14,6 +16,30 @@ func (*noCopy) Unlock() {}
 type ThreadId struct {
 	_        noCopy
 	provider Llm
+	cost     costTracker
+	budget   atomic.Pointer[Budget]
+	policy   atomic.Pointer[HistoryPolicy]
+}
+
+// Cost returns the accumulated cost of all requests executed in the thread.
+func (t *ThreadId) Cost() Cost {
+	return t.cost.total()
+}
+
+// SetBudget subjects all requests executed in the thread to a budget. Copies of
+// the thread are not subject to it.
+func (t *ThreadId) SetBudget(budget *Budget) {
+	t.budget.Store(budget)
+}
+
+// SetHistoryPolicy bounds the history of the thread with the provided policy,
+// applied before each request executed in the thread. Copies of the thread are
+// not subject to it.
+//
+// The provider of the thread must implement `HistoryCompactor`, or requests
+// executed in the thread will fail.
+func (t *ThreadId) SetHistoryPolicy(policy HistoryPolicy) {
+	t.policy.Store(&policy)
 }
 
 func (t *ThreadId) Clear() {
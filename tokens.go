@@ -0,0 +1,45 @@
+package llmberjack
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
+
+// TokenCount is the number of input tokens of a request, as counted before
+// sending it.
+type TokenCount struct {
+	Tokens int
+	// Estimated is set when the count was not computed with the tokenizer of
+	// the model, and is only an approximation.
+	Estimated bool
+}
+
+// CountTokens counts the input tokens of the request on its provider, without
+// sending it, to check whether it fits in the context window of the model.
+//
+// The count covers the history of the thread the request is executed in, the
+// system instructions, and the schemas of tools and of the response. Depending
+// on the provider, it can require a network call, and can be estimated.
+func (r Request[T]) CountTokens(ctx context.Context, llm *Llmberjack) (TokenCount, error) {
+	if r.err != nil {
+		return TokenCount{}, r.err
+	}
+
+	provider, req, err := r.resolve(llm, r.provider)
+	if err != nil {
+		return TokenCount{}, err
+	}
+
+	counter, ok := supports[TokenCounter](provider)
+	if !ok {
+		return TokenCount{}, errors.New("provider does not support counting tokens")
+	}
+
+	count, err := counter.CountTokens(ctx, llm, req)
+	if err != nil {
+		return TokenCount{}, errors.Wrap(err, "could not count tokens")
+	}
+
+	return count, nil
+}
@@ -0,0 +1,45 @@
+package llmberjack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/checkmarble/llmberjack/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockTokenCounter struct {
+	*MockProvider
+}
+
+func (p mockTokenCounter) CountTokens(_ context.Context, _ internal.Adapter, requester Requester) (TokenCount, error) {
+	return TokenCount{Tokens: 10 * len(requester.ToRequest().Messages)}, nil
+}
+
+func TestCountTokens(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(mockTokenCounter{p}))
+
+	count, err := NewUntypedRequest().
+		WithText(RoleUser, "first").
+		WithText(RoleUser, "second").
+		CountTokens(t.Context(), llm)
+
+	assert.Nil(t, err)
+	assert.Equal(t, TokenCount{Tokens: 20}, count)
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCountTokensUnsupported(t *testing.T) {
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p))
+
+	_, err := NewUntypedRequest().CountTokens(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "provider does not support counting tokens")
+}
@@ -0,0 +1,259 @@
+package llmberjack
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/checkmarble/llmberjack"
+
+// Attributes from the OpenTelemetry semantic conventions for generative AI.
+const (
+	attrGenAiSystem                  = attribute.Key("gen_ai.system")
+	attrGenAiOperationName           = attribute.Key("gen_ai.operation.name")
+	attrGenAiRequestModel            = attribute.Key("gen_ai.request.model")
+	attrGenAiRequestMaxTokens        = attribute.Key("gen_ai.request.max_tokens")
+	attrGenAiRequestTemperature      = attribute.Key("gen_ai.request.temperature")
+	attrGenAiRequestTopP             = attribute.Key("gen_ai.request.top_p")
+	attrGenAiRequestStopSequences    = attribute.Key("gen_ai.request.stop_sequences")
+	attrGenAiRequestSeed             = attribute.Key("gen_ai.request.seed")
+	attrGenAiRequestPresencePenalty  = attribute.Key("gen_ai.request.presence_penalty")
+	attrGenAiRequestFrequencyPenalty = attribute.Key("gen_ai.request.frequency_penalty")
+	attrGenAiResponseId              = attribute.Key("gen_ai.response.id")
+	attrGenAiResponseModel           = attribute.Key("gen_ai.response.model")
+	attrGenAiResponseFinishReasons   = attribute.Key("gen_ai.response.finish_reasons")
+	attrGenAiUsageInputTokens        = attribute.Key("gen_ai.usage.input_tokens")
+	attrGenAiUsageOutputTokens       = attribute.Key("gen_ai.usage.output_tokens")
+	attrGenAiToolName                = attribute.Key("gen_ai.tool.name")
+	attrGenAiToolCallId              = attribute.Key("gen_ai.tool.call.id")
+	attrGenAiPrompt                  = attribute.Key("gen_ai.prompt")
+	attrGenAiCompletion              = attribute.Key("gen_ai.completion")
+)
+
+// Attributes specific to this library.
+const (
+	attrProvider         = attribute.Key("llmberjack.provider")
+	attrFallbackProvider = attribute.Key("llmberjack.fallback.provider")
+	attrRetryAttempt     = attribute.Key("llmberjack.retry.attempt")
+	attrRetryDelay       = attribute.Key("llmberjack.retry.delay_ms")
+	attrCached           = attribute.Key("llmberjack.cached")
+	attrCost             = attribute.Key("llmberjack.cost")
+	attrError            = attribute.Key("llmberjack.error")
+)
+
+// Tracing configures OpenTelemetry instrumentation.
+type Tracing struct {
+	// TracerProvider is used to create spans. If not provided, the global
+	// tracer provider is used.
+	TracerProvider trace.TracerProvider
+	// CaptureContent records the content of prompts and responses as span
+	// events. It is disabled by default, since it may contain sensitive data.
+	CaptureContent bool
+}
+
+// toolExecution records the execution of a tool, to be traced when the request
+// it responds to is executed.
+type toolExecution struct {
+	call  ResponseToolCall
+	start time.Time
+	end   time.Time
+	err   error
+}
+
+func (llm *Llmberjack) tracer() trace.Tracer {
+	switch {
+	case llm.tracing == nil:
+		return noop.NewTracerProvider().Tracer(tracerName)
+	case llm.tracing.TracerProvider == nil:
+		return otel.GetTracerProvider().Tracer(tracerName)
+	default:
+		return llm.tracing.TracerProvider.Tracer(tracerName)
+	}
+}
+
+// startSpan opens the span covering the execution of a request, including
+// retries and fallbacks, and the execution of the tools it responds to.
+func (r Request[T]) startSpan(ctx context.Context, llm *Llmberjack) (context.Context, trace.Span) {
+	tracer := llm.tracer()
+
+	ctx, span := tracer.Start(ctx, "chat", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attrGenAiOperationName.String("chat"),
+	))
+
+	for _, exec := range r.toolExecutions {
+		_, toolSpan := tracer.Start(ctx, "execute_tool "+exec.call.Name, trace.WithTimestamp(exec.start), trace.WithAttributes(
+			attrGenAiOperationName.String("execute_tool"),
+			attrGenAiToolName.String(exec.call.Name),
+			attrGenAiToolCallId.String(exec.call.Id),
+		))
+
+		if exec.err != nil {
+			toolSpan.RecordError(exec.err)
+			toolSpan.SetStatus(codes.Error, exec.err.Error())
+		}
+
+		toolSpan.End(trace.WithTimestamp(exec.end))
+	}
+
+	return ctx, span
+}
+
+// traceRequest records the attributes of a request sent to a provider.
+func (llm *Llmberjack) traceRequest(span trace.Span, providerName string, provider Llm, model string, req innerRequest) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetName("chat " + model)
+	span.SetAttributes(
+		attrGenAiSystem.String(providerSystem(providerName, provider)),
+		attrProvider.String(providerName),
+		attrGenAiRequestModel.String(model),
+	)
+
+	if req.MaxTokens != nil {
+		span.SetAttributes(attrGenAiRequestMaxTokens.Int(*req.MaxTokens))
+	}
+	if req.Temperature != nil {
+		span.SetAttributes(attrGenAiRequestTemperature.Float64(*req.Temperature))
+	}
+	if req.TopP != nil {
+		span.SetAttributes(attrGenAiRequestTopP.Float64(*req.TopP))
+	}
+	if len(req.StopSequences) > 0 {
+		span.SetAttributes(attrGenAiRequestStopSequences.StringSlice(req.StopSequences))
+	}
+	if req.Seed != nil {
+		span.SetAttributes(attrGenAiRequestSeed.Int(*req.Seed))
+	}
+	if req.PresencePenalty != nil {
+		span.SetAttributes(attrGenAiRequestPresencePenalty.Float64(*req.PresencePenalty))
+	}
+	if req.FrequencyPenalty != nil {
+		span.SetAttributes(attrGenAiRequestFrequencyPenalty.Float64(*req.FrequencyPenalty))
+	}
+
+	if llm.tracing.CaptureContent {
+		type prompt struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}
+
+		prompts := make([]prompt, 0, len(req.Messages))
+
+		for _, msg := range req.Messages {
+			if msg.Url != "" {
+				prompts = append(prompts, prompt{Role: roleName(msg.Role), Content: msg.Url})
+			}
+
+			for _, part := range msg.Parts {
+				content, ok := describePart(msg, part)
+				if !ok {
+					content = "<unreadable>"
+				}
+
+				prompts = append(prompts, prompt{Role: roleName(msg.Role), Content: content})
+			}
+		}
+
+		if buf, err := json.Marshal(prompts); err == nil {
+			span.AddEvent("gen_ai.content.prompt", trace.WithAttributes(attrGenAiPrompt.String(string(buf))))
+		}
+	}
+}
+
+// traceResponse records the attributes of a response received from a provider.
+func (llm *Llmberjack) traceResponse(span trace.Span, resp *InnerResponse, cost Cost) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		attrGenAiResponseId.String(resp.Id),
+		attrGenAiResponseModel.String(resp.Model),
+		attrGenAiResponseFinishReasons.StringSlice(lo.Map(resp.Candidates, func(c ResponseCandidate, _ int) string {
+			return string(c.FinishReason)
+		})),
+		attrGenAiUsageInputTokens.Int(resp.Usage.PromptTokens),
+		attrGenAiUsageOutputTokens.Int(resp.Usage.CompletionTokens),
+		attrCached.Bool(resp.Cached),
+	)
+
+	if !cost.Unknown {
+		span.SetAttributes(attrCost.Float64(cost.Amount))
+	}
+
+	if llm.tracing.CaptureContent {
+		type completion struct {
+			Content   string             `json:"content,omitempty"`
+			ToolCalls []ResponseToolCall `json:"tool_calls,omitempty"`
+		}
+
+		completions := lo.Map(resp.Candidates, func(c ResponseCandidate, _ int) completion {
+			return completion{Content: c.Text, ToolCalls: c.ToolCalls}
+		})
+
+		if buf, err := json.Marshal(completions); err == nil {
+			span.AddEvent("gen_ai.content.completion", trace.WithAttributes(attrGenAiCompletion.String(string(buf))))
+		}
+	}
+}
+
+// traceError marks the span of a request as failed.
+func traceError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// traceFallback records that a request is sent to a fallback provider after
+// the previous one failed.
+func traceFallback(span trace.Span, providerName string, err error) {
+	span.AddEvent("llmberjack.fallback", trace.WithAttributes(
+		attrFallbackProvider.String(providerName),
+		attrError.String(err.Error()),
+	))
+}
+
+// traceRetry records that a request is retried after it failed.
+func traceRetry(ctx context.Context, attempt int, delay time.Duration, err error) {
+	trace.SpanFromContext(ctx).AddEvent("llmberjack.retry", trace.WithAttributes(
+		attrRetryAttempt.Int(attempt),
+		attrRetryDelay.Int64(delay.Milliseconds()),
+		attrError.String(err.Error()),
+	))
+}
+
+// providerSystem names the system a provider sends requests to, defaulting to
+// the name it was registered under.
+func providerSystem(providerName string, provider Llm) string {
+	if namer, ok := provider.(SystemNamer); ok {
+		if system := namer.SystemName(); system != "" {
+			return system
+		}
+	}
+
+	return providerName
+}
+
+func roleName(role MessageRole) string {
+	switch role {
+	case RoleSystem:
+		return "system"
+	case RoleUser:
+		return "user"
+	case RoleAi:
+		return "assistant"
+	case RoleTool:
+		return "tool"
+	default:
+		return "unknown"
+	}
+}
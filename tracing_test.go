@@ -0,0 +1,274 @@
+package llmberjack
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	return lo.SliceToMap(span.Attributes, func(kv attribute.KeyValue) (attribute.Key, attribute.Value) {
+		return kv.Key, kv.Value
+	})
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p1 := NewMockProvider()
+	p1.On("Init", mock.Anything).Return(nil)
+	p2 := NewMockProvider()
+	p2.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(
+		WithProvider("p1", p1),
+		WithProvider("p2", p2),
+		WithDefaultModel("themodel"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		WithMiddleware(withTestUsage(Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30})),
+		WithTracing(Tracing{TracerProvider: tp}),
+	)
+
+	p1.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(nil, &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")})
+	p2.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err := NewUntypedRequest().
+		WithText(RoleUser, "secret prompt").
+		WithMaxTokens(100).
+		WithTemperature(0.5).
+		WithFallbackProviders("p2").
+		Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "chat themodel", spans[0].Name)
+
+	attrs := spanAttributes(spans[0])
+
+	assert.Equal(t, "chat", attrs["gen_ai.operation.name"].AsString())
+	assert.Equal(t, "p2", attrs["gen_ai.system"].AsString())
+	assert.Equal(t, "p2", attrs["llmberjack.provider"].AsString())
+	assert.Equal(t, "themodel", attrs["gen_ai.request.model"].AsString())
+	assert.Equal(t, "themodel", attrs["gen_ai.response.model"].AsString())
+	assert.EqualValues(t, 100, attrs["gen_ai.request.max_tokens"].AsInt64())
+	assert.Equal(t, 0.5, attrs["gen_ai.request.temperature"].AsFloat64())
+	assert.EqualValues(t, 10, attrs["gen_ai.usage.input_tokens"].AsInt64())
+	assert.EqualValues(t, 20, attrs["gen_ai.usage.output_tokens"].AsInt64())
+	assert.Equal(t, []string{""}, attrs["gen_ai.response.finish_reasons"].AsStringSlice())
+
+	events := lo.Map(spans[0].Events, func(e sdktrace.Event, _ int) string { return e.Name })
+
+	assert.Equal(t, []string{"llmberjack.retry", "llmberjack.fallback"}, events)
+
+	exporter.Reset()
+
+	_, err = NewUntypedRequest().WithProvider("p1").Do(t.Context(), llm)
+
+	assert.NotNil(t, err)
+
+	spans = exporter.GetSpans()
+
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
+
+func TestTracingToolsAndContent(t *testing.T) {
+	type Args struct {
+		Integer int `json:"integer"`
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithTracing(Tracing{TracerProvider: tp, CaptureContent: true}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	tool := NewTool[Args]("thetool", "", Function(func(args Args) (string, error) {
+		return "called", nil
+	}))
+
+	resp := Response[string]{
+		ThreadId: &ThreadId{provider: p},
+		InnerResponse: InnerResponse{
+			Candidates: []ResponseCandidate{{
+				ToolCalls:       []ResponseToolCall{{Id: "id", Name: "thetool", Parameters: []byte(`{"integer": 10}`)}},
+				SelectCandidate: func() {},
+			}},
+		},
+	}
+
+	_, err := NewUntypedRequest().FromCandidate(resp, 0).WithToolExecution(tool).Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+
+	assert.Len(t, spans, 2)
+
+	toolSpan, _ := lo.Find(spans, func(s tracetest.SpanStub) bool { return s.Name == "execute_tool thetool" })
+	chatSpan, _ := lo.Find(spans, func(s tracetest.SpanStub) bool { return s.Name == "chat " })
+
+	assert.Equal(t, chatSpan.SpanContext.SpanID(), toolSpan.Parent.SpanID())
+	assert.Equal(t, "id", spanAttributes(toolSpan)["gen_ai.tool.call.id"].AsString())
+
+	events := lo.SliceToMap(chatSpan.Events, func(e sdktrace.Event) (string, string) {
+		return e.Name, e.Attributes[0].Value.AsString()
+	})
+
+	assert.Equal(t, `[{"role":"tool","content":"called"}]`, events["gen_ai.content.prompt"])
+	assert.Equal(t, `[{"content":"Hello"}]`, events["gen_ai.content.completion"])
+}
+
+func TestTracingTools(t *testing.T) {
+	type Args struct {
+		Integer int `json:"integer"`
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	tool := NewTool[Args]("thetool", "", Function(func(args Args) (string, error) {
+		return "", errors.New("tool failed")
+	}))
+
+	resp := Response[string]{
+		ThreadId: &ThreadId{provider: p},
+		InnerResponse: InnerResponse{
+			Candidates: []ResponseCandidate{{
+				ToolCalls:       []ResponseToolCall{{Id: "id", Name: "thetool", Parameters: []byte(`{"integer": 10}`)}},
+				SelectCandidate: func() {},
+			}},
+		},
+	}
+
+	// Tools are not traced if tracing is not enabled on the adapter, even if
+	// the caller is traced.
+	ctx, parent := tp.Tracer("test").Start(t.Context(), "parent")
+
+	untraced, _ := New(WithDefaultProvider(p))
+
+	_, err := NewUntypedRequest().FromCandidate(resp, 0).WithToolExecution(tool).Do(ctx, untraced)
+
+	parent.End()
+
+	assert.ErrorContains(t, err, "tool failed")
+	assert.Len(t, exporter.GetSpans(), 1)
+
+	exporter.Reset()
+
+	// Failed tools are traced under the span of the request.
+	llm, _ := New(WithDefaultProvider(p), WithTracing(Tracing{TracerProvider: tp}))
+
+	_, err = NewUntypedRequest().FromCandidate(resp, 0).WithToolExecution(tool).Do(t.Context(), llm)
+
+	assert.ErrorContains(t, err, "tool failed")
+
+	spans := exporter.GetSpans()
+
+	assert.Len(t, spans, 2)
+
+	toolSpan, _ := lo.Find(spans, func(s tracetest.SpanStub) bool { return s.Name == "execute_tool thetool" })
+	chatSpan, _ := lo.Find(spans, func(s tracetest.SpanStub) bool { return s.Name == "chat" })
+
+	assert.Equal(t, chatSpan.SpanContext.SpanID(), toolSpan.Parent.SpanID())
+	assert.Equal(t, codes.Error, toolSpan.Status.Code)
+	assert.Equal(t, codes.Error, chatSpan.Status.Code)
+	p.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTracingNoContentByDefault(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := NewMockProvider()
+	p.On("Init", mock.Anything).Return(nil)
+
+	llm, _ := New(WithDefaultProvider(p), WithTracing(Tracing{TracerProvider: tp}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, _ = NewUntypedRequest().WithText(RoleUser, "secret prompt").Do(t.Context(), llm)
+
+	spans := exporter.GetSpans()
+
+	assert.Len(t, spans, 1)
+	assert.Empty(t, spans[0].Events)
+}
+
+type namedMockProvider struct {
+	*MockProvider
+}
+
+func (namedMockProvider) SystemName() string {
+	return "thesystem"
+}
+
+func TestTracingSystemName(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := namedMockProvider{NewMockProvider()}
+	p.On("Init", mock.Anything).Return(nil)
+
+	recorder, _ := NewRecorder(p, filepath.Join(t.TempDir(), "cassette.jsonl"), RecordModeRecord)
+	llm, _ := New(WithProvider("theprovider", recorder), WithTracing(Tracing{TracerProvider: tp}))
+
+	p.On("ChatCompletion", mock.Anything, llm, mock.Anything).Return(MockMessage{"Hello"}, nil)
+
+	_, err := NewUntypedRequest().WithText(RoleUser, "Hello").Do(t.Context(), llm)
+
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "thesystem", spanAttributes(spans[0])["gen_ai.system"].AsString())
+}
+
+// The OpenTelemetry SDK must only be used by tests, so users of the library only
+// depend on the API.
+func TestTracingOnlyDependsOnApi(t *testing.T) {
+	err := filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+
+		for _, spec := range file.Imports {
+			assert.False(t, strings.HasPrefix(strings.Trim(spec.Path.Value, `"`), "go.opentelemetry.io/otel/sdk"), path)
+		}
+
+		return nil
+	})
+
+	assert.Nil(t, err)
+}